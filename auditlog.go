@@ -0,0 +1,152 @@
+// rem - A tool to remember things on the command line.
+// Copyright (C) 2015 Martin Borho (martin@borho.net)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A command run in child mode.
+type execRecord struct {
+	ID       string    `json:"id"`
+	Tags     []string  `json:"tags,omitempty"`
+	Cmd      string    `json:"cmd"`
+	Cwd      string    `json:"cwd"`
+	File     string    `json:"file"`
+	Start    time.Time `json:"start"`
+	Duration float64   `json:"duration"` // seconds
+	Exit     int       `json:"exit"`
+	User     string    `json:"user"`
+}
+
+// Returns the path of the log of run commands.
+func execLogPath() string {
+	return path.Join(dataDir(), "exec.log")
+}
+
+// Appends a record to the log of run commands.
+func appendExecLog(rec *execRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataDir(), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(execLogPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	// a single write, appends of several rem processes don't mix
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// Returns the records of the log matching the filter, oldest first.
+func readExecLog(filter func(*execRecord) bool) ([]*execRecord, error) {
+	records := []*execRecord{}
+	file, err := os.Open(execLogPath())
+	if os.IsNotExist(err) {
+		return records, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		rec := &execRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), rec); err != nil {
+			// skip lines broken e.g. by a full disk
+			continue
+		}
+		if filter(rec) {
+			records = append(records, rec)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// Parses the start of a time range like 30m, 12h, 1d, 2w or 2023-04-01.
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+	// days and weeks, which time.ParseDuration doesn't know
+	units := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if unit, ok := units[since[len(since)-1]]; ok {
+		if n, err := strconv.Atoi(since[:len(since)-1]); err == nil {
+			return now.Add(-time.Duration(n) * unit), nil
+		}
+	}
+	if d, err := time.ParseDuration(since); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", since, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("Invalid time %s, use e.g. 30m, 12h, 1d, 2w or 2006-01-02.", since)
+}
+
+// Prints the log of run commands, filtered by tag or id, start time
+// and exit status.
+func (r *Rem) printExecLog(tag, since string, failed bool) error {
+	start, err := parseSince(since, time.Now())
+	if err != nil {
+		return err
+	}
+	records, err := readExecLog(func(rec *execRecord) bool {
+		if failed && rec.Exit == 0 {
+			return false
+		}
+		if rec.Start.Before(start) {
+			return false
+		}
+		if tag == "" || rec.ID == tag {
+			return true
+		}
+		for _, t := range rec.Tags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	w := r.getTabWriter()
+	for _, rec := range records {
+		name := rec.ID
+		if len(rec.Tags) > 0 {
+			name = strings.Join(rec.Tags, ",")
+		}
+		duration := time.Duration(rec.Duration * float64(time.Second)).Round(time.Millisecond)
+		fmt.Fprintf(w, " %s\t%s\texit %d\t%s\t%s\t%s\n", rec.Start.Local().Format("2006-01-02 15:04:05"),
+			rec.User, rec.Exit, duration, name, (&Line{cmd: rec.Cmd}).summary())
+	}
+	return w.Flush()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2023, 4, 10, 12, 0, 0, 0, time.Local)
+	cases := map[string]time.Time{
+		"":           {},
+		"30m":        now.Add(-30 * time.Minute),
+		"12h":        now.Add(-12 * time.Hour),
+		"1d":         now.Add(-24 * time.Hour),
+		"2w":         now.Add(-14 * 24 * time.Hour),
+		"2023-04-01": time.Date(2023, 4, 1, 0, 0, 0, 0, time.Local),
+	}
+	for since, expected := range cases {
+		if start, err := parseSince(since, now); err != nil || !start.Equal(expected) {
+			t.Errorf("Wrong start for %s, got %s %v", since, start, err)
+		}
+	}
+	for _, since := range []string{"12", "d", "yesterday"} {
+		if _, err := parseSince(since, now); err == nil {
+			t.Errorf("No error for %s", since)
+		}
+	}
+}
+
+func TestExecLog(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	useShell(t, "/bin/sh")
+	rem := getRem(t, "#ok#true\n#fail#exit 3\n")
+	defer removeRemFile(rem)
+	rem.read()
+	rem.child = true
+
+	rem.executeIndex(0, nil, []string{"a b"})
+	rem.executeIndex(1, nil, nil)
+
+	records, err := readExecLog(func(*execRecord) bool { return true })
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Wrong number of records, got %d %v", len(records), err)
+	}
+	cwd, _ := os.Getwd()
+	rec := records[0]
+	if rec.ID != rem.lines[0].id || rec.Tags[0] != "ok" || rec.Cmd != "true 'a b'" || rec.Cwd != cwd || rec.File != rem.filepath {
+		t.Errorf("Wrong record, got %+v", rec)
+	}
+	if rec.Exit != 0 || records[1].Exit != 3 || rec.User == "" || time.Since(rec.Start) > time.Minute {
+		t.Errorf("Wrong status, got %+v", rec)
+	}
+
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	err = rem.printExecLog("", "1h", true)
+	rem.printExecLog("fail", "", false)
+	rem.printExecLog(rem.lines[0].id, "", true)
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+	os.Stdout = rescueStdout
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if err != nil || len(lines) != 2 || !strings.Contains(lines[0], "exit 3") || !strings.HasSuffix(lines[1], "fail  exit 3") {
+		t.Errorf("Wrong log output, got %s %v", out, err)
+	}
+}
@@ -17,18 +17,31 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"strings"
 )
 
 var (
-	globalFlag *bool
-	helpFlag   *bool
-	addFlag    *bool
-	tagFlag    *string
-	printFlag  *bool
-	filter     *string
+	globalFlag   *bool
+	helpFlag     *bool
+	addFlag      *bool
+	tagFlag      *string
+	printFlag    *bool
+	multiFlag    *bool
+	rawFlag      *bool
+	forceFlag    *bool
+	descFlag     *string
+	replaceFlag  *bool
+	yesFlag      *bool
+	cascadeFlag  *bool
+	notebookFlag *string
+	lastFlag     *bool
+	childFlag    *bool
+	timeFlag     *bool
+	sortFlag     *string
+	filter       *string
 )
 
 func init() {
@@ -36,8 +49,20 @@ func init() {
 	globalFlag = flag.Bool("g", false, "use global rem file")
 	helpFlag = flag.Bool("h", false, "show this help")
 	addFlag = flag.Bool("a", false, "add a command")
-	tagFlag = flag.String("t", "", "tag for command")
+	tagFlag = flag.String("t", "", "comma separated tags for command")
+	descFlag = flag.String("d", "", "description for command")
+	replaceFlag = flag.Bool("replace", false, "replace line using the same tag")
+	yesFlag = flag.Bool("y", false, "don't ask for confirmation")
+	notebookFlag = flag.String("n", "", "use the named notebook")
+	cascadeFlag = flag.Bool("c", false, "cascade rem files of parent dirs and the global one")
+	childFlag = flag.Bool("child", false, "run command as child process instead of replacing rem")
+	timeFlag = flag.Bool("time", false, "print exit status and wall time after running the command")
 	printFlag = flag.Bool("p", false, "print command before executing")
+	multiFlag = flag.Bool("m", false, "read multi-line command from stdin until EOF")
+	lastFlag = flag.Bool("last", false, "add the previous command from the shell history")
+	rawFlag = flag.Bool("r", false, "add arguments as they are, without quoting")
+	forceFlag = flag.Bool("force", false, "use index even if the line changed since the last listing")
+	sortFlag = flag.String("sort", "", "sort listing by frecency, recent, count or alpha")
 	filter = flag.String("f", "", "List commands by regexp filter.")
 }
 
@@ -52,12 +77,40 @@ func run(remfile string) error {
 			global:   *globalFlag,
 		},
 		printBeforeExec: *printFlag,
+		child:           *childFlag || config.ExecMode == "child",
+		timing:          *timeFlag || config.Timing,
+		force:           *forceFlag,
+		replace:         *replaceFlag,
+	}
+	if *notebookFlag != "" {
+		if *globalFlag == true {
+			return errors.New("Use either -g or -n.")
+		}
+		if err := rem.useNotebook(*notebookFlag); err != nil {
+			return err
+		}
+	}
+	if err := rem.read(); err != nil {
+		return fmt.Errorf("%s: %s", rem.filepath, err)
+	}
+	if *cascadeFlag == true {
+		if err := rem.readLayers(); err != nil {
+			return err
+		}
+	}
+	if err := rem.readShared(); err != nil {
+		return err
+	}
+	if *sortFlag != "" {
+		if err := rem.setSort(*sortFlag); err != nil {
+			return err
+		}
 	}
-	rem.read()
 
 	// check flags and run specific method.
 	var err error
 	var index int
+	var target *Rem
 
 	remCmd := flag.Arg(0)
 	switch {
@@ -66,49 +119,197 @@ func run(remfile string) error {
 	case remCmd == "here":
 		err = rem.createLocalFile()
 	case remCmd == "clear":
-		err = rem.clearFile()
+		if len(rem.lines) > 0 && *yesFlag == false && config.Confirm &&
+			!confirm(fmt.Sprintf("Clear %d lines from %s?", len(rem.lines), rem.filepath)) {
+			break
+		}
+		err = rem.clear()
+	case remCmd == "notebooks":
+		err = rem.printNotebooks()
+	case remCmd == "cp" || remCmd == "mv":
+		if target, index, err = rem.locate(flag.Arg(1)); err == nil {
+			var to *Rem
+			if flag.Arg(2) == "" {
+				err = errors.New("Need a notebook, global or local as target.")
+			} else if to, err = target.collection(flag.Arg(2)); err == nil && remCmd == "cp" {
+				err = target.copyLine(index, to)
+			} else if err == nil {
+				err = target.moveLine(index, to)
+			}
+		}
+	case remCmd == "import-history":
+		flags := flag.NewFlagSet("import-history", flag.ContinueOnError)
+		shell := flags.String("shell", currentShell(), "shell to read the history of, bash, zsh or fish")
+		file := flags.String("file", "", "history file")
+		limit := flags.Int("limit", 20, "number of recent commands to choose from")
+		if err = flags.Parse(flag.Args()[1:]); err == nil {
+			err = rem.importHistory(*shell, *file, *limit)
+		}
+	case remCmd == "discover":
+		flags := flag.NewFlagSet("discover", flag.ContinueOnError)
+		all := flags.Bool("all", false, "add all new tasks without asking")
+		if err = flags.Parse(flag.Args()[1:]); err == nil {
+			err = rem.discover(*all)
+		}
+	case remCmd == "export-shell":
+		flags := flag.NewFlagSet("export-shell", flag.ContinueOnError)
+		shell := flags.String("shell", currentShell(), "bash, zsh or fish")
+		abbr := flags.Bool("abbr", false, "fish abbreviations instead of functions")
+		if err = flags.Parse(flag.Args()[1:]); err == nil {
+			err = rem.exportShell(*shell, *abbr)
+		}
+	case remCmd == "export":
+		flags := flag.NewFlagSet("export", flag.ContinueOnError)
+		format := flags.String("format", "json", "json, yaml, csv or md")
+		output := flags.String("output", "", "file to write to instead of stdout")
+		if _, err = parseArgs(flags, flag.Args()[1:]); err == nil {
+			err = rem.export(*format, *output)
+		}
+	case remCmd == "import":
+		flags := flag.NewFlagSet("import", flag.ContinueOnError)
+		format := flags.String("format", "", "json, yaml, csv or md, default by file extension")
+		opts := importOptions{}
+		flags.BoolVar(&opts.skipDuplicates, "skip-duplicates", false, "skip commands which exist already")
+		flags.BoolVar(&opts.overwriteTags, "overwrite-tags", false, "take used tags away from existing commands")
+		flags.BoolVar(&opts.renumber, "renumber", false, "give all imported commands new ids")
+		var files []string
+		if files, err = parseArgs(flags, flag.Args()[1:]); err != nil {
+			break
+		}
+		if len(files) != 1 {
+			err = errors.New("Need one file to import, - for stdin.")
+			break
+		}
+		if *format == "" {
+			if *format, err = formatFromPath(files[0]); err != nil {
+				break
+			}
+		}
+		err = rem.importFile(files[0], *format, opts)
+	case remCmd == "log":
+		flags := flag.NewFlagSet("log", flag.ContinueOnError)
+		tag := flags.String("tag", "", "only commands with this tag or id")
+		since := flags.String("since", "", "only commands run since, e.g. 30m, 12h, 1d, 2w or 2006-01-02")
+		failed := flags.Bool("failed", false, "only commands with an exit status other than 0")
+		if err = flags.Parse(flag.Args()[1:]); err == nil {
+			err = rem.printExecLog(*tag, *since, *failed)
+		}
+	case remCmd == "stats":
+		err = rem.printStats()
+	case remCmd == "undo":
+		err = rem.undo()
+	case remCmd == "trash":
+		if flag.Arg(1) == "restore" {
+			if index, err = toInt(flag.Arg(2)); err == nil {
+				err = rem.restore(index)
+			}
+		} else {
+			err = rem.printTrash()
+		}
 	case (remCmd == "add" || *addFlag == true):
 		startIndex := 1
 		if *addFlag == true {
 			startIndex = 0
 		}
-		toAdd := strings.TrimSpace(strings.Join(flag.Args()[startIndex:], " "))
-		if toAdd == "" {
+		args := flag.Args()[startIndex:]
+		toAdd := ""
+		if *lastFlag == true || (len(args) == 1 && args[0] == "--last") {
+			// previous command of the shell
+			if toAdd, err = lastCommand(); err != nil {
+				break
+			}
+			fmt.Println(toAdd)
+		} else if *rawFlag == true || len(args) == 1 {
+			// pre-quoted command line
+			toAdd = strings.TrimSpace(strings.Join(args, " "))
+		} else {
+			// keep the argv as typed
+			toAdd = shellJoin(args)
+		}
+		if toAdd == "" && *multiFlag == true {
+			// read whole script from stdIn
+			if toAdd, err = rem.readAllFromStdIn(); err != nil {
+				break
+			}
+		} else if toAdd == "" {
 			// read line from stdIn
 			toAdd = rem.readFromStdIn()
 		}
-		err = rem.appendLine(toAdd, *tagFlag)
+		err = rem.addLine(&Line{
+			cmd:         toAdd,
+			tags:        parseTags(*tagFlag),
+			description: *descFlag,
+		})
 	case (remCmd == "filter"):
 		err = rem.filterLines(strings.Join(flag.Args()[1:], " "))
 	case *filter != "":
 		err = rem.filterLines(*filter)
 	case remCmd == "edit":
-		if index, err = toInt(flag.Arg(1)); err == nil {
-			err = rem.editIndex(index)
-		} else {
-			err = rem.editTag(flag.Arg(1))
+		if target, index, err = rem.locate(flag.Arg(1)); err == nil {
+			err = target.editIndex(index)
+		}
+	case remCmd == "info":
+		if target, index, err = rem.locate(flag.Arg(1)); err == nil {
+			err = target.printInfo(index)
+		}
+	case remCmd == "tag":
+		if target, index, err = rem.locate(flag.Arg(1)); err == nil {
+			tags := parseTags(strings.Join(flag.Args()[2:], ","))
+			if len(tags) == 0 {
+				err = errors.New("Need a tag.")
+			} else {
+				err = target.retag(index, tags)
+			}
+		}
+	case remCmd == "untag":
+		if target, index, err = rem.locate(flag.Arg(1)); err == nil {
+			err = target.retag(index, []string{})
+		}
+	case remCmd == "history":
+		if target, index, err = rem.locate(flag.Arg(1)); err == nil {
+			err = target.printHistory(index)
+		}
+	case remCmd == "revert":
+		if target, index, err = rem.locate(flag.Arg(1)); err == nil {
+			var rev int
+			if rev, err = toInt(flag.Arg(2)); err == nil {
+				err = target.revert(index, rev)
+			}
 		}
 	case remCmd == "rm":
-		if index, err = toInt(flag.Arg(1)); err == nil {
-			err = rem.removeLine(index)
+		if target, index, err = rem.locate(flag.Arg(1)); err == nil {
+			err = target.removeLine(index)
 		}
 	case remCmd == "echo":
 		if flag.Arg(1) != "" {
-			if index, err = toInt(flag.Arg(1)); err == nil {
-				err = rem.printLine(index)
-			} else {
-				err = rem.printTag(flag.Arg(1))
+			var values map[string]string
+			var args []string
+			if values, args, err = parseValues(flag.Args()[2:]); err != nil {
+				break
+			}
+			if target, index, err = rem.locate(flag.Arg(1)); err == nil {
+				var cmd string
+				if cmd, err = target.expandIndex(index, values); err == nil {
+					expanded := &Line{cmd: cmd}
+					expanded.warnUnusedArgs(args)
+					fmt.Println(expanded.withArgs(args))
+				}
 			}
 		}
 	case remCmd != "":
-		if index, err = toInt(remCmd); err == nil {
-			err = rem.executeIndex(index)
-		} else {
-			err = rem.executeTag(remCmd)
+		var values map[string]string
+		var args []string
+		if values, args, err = parseValues(flag.Args()[1:]); err != nil {
+			break
+		}
+		if target, index, err = rem.locate(remCmd); err == nil {
+			err = target.executeIndex(index, values, args)
 		}
+	case *tagFlag != "":
+		rem.printTagged(parseTags(*tagFlag))
 	default:
 		rem.printAllLines()
-		if len(rem.lines) == 0 {
+		if rem.countLines() == 0 {
 			// show help if nothing was found
 			fmt.Println(help)
 		}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"strings"
@@ -25,7 +26,7 @@ func TestRunDefault(t *testing.T) {
 	out, _ := ioutil.ReadAll(r)
 	os.Stdout = rescueStdout
 
-	if string(out) != " 0   -   ls\n 1  foo  ls -la\n 2   -   echo test\n" {
+	if string(out) != " 0  qebpwy   -   ls\n 1  rwz6fj  foo  ls -la\n 2  kft8sg   -   echo test\n" {
 		t.Errorf("Wrong line output, got %s", out)
 	}
 }
@@ -61,7 +62,11 @@ func TestRunRmAdd(t *testing.T) {
 	w.Close()
 	out, _ := ioutil.ReadAll(r)
 	os.Stdout = rescueStdout
-	if string(out) != " 0  ls\n 1  echo test\n 2  pwd\n" {
+
+	// ids of existing lines don't change, added line gets a new one
+	rem.read()
+	expected := fmt.Sprintf(" 0  qebpwy  ls\n 1  kft8sg  echo test\n 2  %s  pwd\n", rem.lines[2].id)
+	if string(out) != expected {
 		t.Errorf("Wrong line output, got %s", out)
 	}
 }
@@ -98,6 +103,141 @@ func TestRunRmAdd(t *testing.T) {
 	}
 }*/
 
+func TestRunAddMultiLine(t *testing.T) {
+	// create test file
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	script := "cat <<EOF\nfoo\n\nbar\nEOF"
+	funcDefer, err := mockStdin(t, script+"\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer funcDefer()
+
+	os.Args = []string{"", "-m", "add"}
+	if err := run(testRemFile); err != nil {
+		t.Errorf("Error when adding multi-line command, got %s", err)
+	}
+	*multiFlag = false
+
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	os.Args = []string{"", "echo", "3"}
+	err = run(testRemFile)
+
+	os.Args = []string{""}
+	run(testRemFile)
+
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+	os.Stdout = rescueStdout
+
+	if err != nil {
+		t.Errorf("Error when echoing line, got %s", err)
+	}
+	rem.read()
+	expected := fmt.Sprintf(" 0  qebpwy   -   ls\n 1  rwz6fj  foo  ls -la\n 2  kft8sg   -   echo test\n 3  %s   -   cat <<EOF (+4 lines)\n", rem.lines[3].id)
+	if string(out) != script+"\n"+expected {
+		t.Errorf("Wrong multi-line output, got %s", out)
+	}
+}
+
+func TestRunAddQuoted(t *testing.T) {
+	// create test file
+	rem := getTestEmptyRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	os.Args = []string{"", "add", "grep", "foo bar", "file"}
+	if err := run(testRemFile); err != nil {
+		t.Errorf("Error when adding line, got %s", err)
+	}
+	os.Args = []string{"", "add", "cat $HOME/.bashrc | wc"}
+	if err := run(testRemFile); err != nil {
+		t.Errorf("Error when adding line, got %s", err)
+	}
+	os.Args = []string{"", "-r", "add", "ls", "|", "wc -l"}
+	if err := run(testRemFile); err != nil {
+		t.Errorf("Error when adding raw line, got %s", err)
+	}
+	*rawFlag = false
+
+	rem.read()
+	expected := []string{"grep 'foo bar' file", "cat $HOME/.bashrc | wc", "ls | wc -l"}
+	for i, cmd := range expected {
+		if rem.lines[i].cmd != cmd {
+			t.Errorf("Wrong command saved, want %s, got %s", cmd, rem.lines[i].cmd)
+		}
+	}
+}
+
+func TestRunAddInfo(t *testing.T) {
+	// create test file
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	os.Args = []string{"", "-t", "restore", "-d", "restore prod db dump", "add", "pg_restore", "dump.sql"}
+	if err := run(testRemFile); err != nil {
+		t.Errorf("Error when adding line, got %s", err)
+	}
+	*tagFlag = ""
+	*descFlag = ""
+
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	os.Args = []string{"", "info", "restore"}
+	err := run(testRemFile)
+
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+	os.Stdout = rescueStdout
+
+	if err != nil {
+		t.Errorf("Error when showing info, got %s", err)
+	}
+	for _, expected := range []string{
+		"description:  restore prod db dump\n",
+		"author:       " + currentAuthor() + "\n",
+		"command:      pg_restore dump.sql\n",
+	} {
+		if !strings.Contains(string(out), expected) {
+			t.Errorf("Info misses %s, got %s", expected, out)
+		}
+	}
+}
+
+func TestRunTagUntag(t *testing.T) {
+	// create test file
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	os.Args = []string{"", "tag", "0", "list"}
+	if err := run(testRemFile); err != nil {
+		t.Errorf("Error when tagging line, got %s", err)
+	}
+	os.Args = []string{"", "untag", "foo"}
+	if err := run(testRemFile); err != nil {
+		t.Errorf("Error when untagging line, got %s", err)
+	}
+	os.Args = []string{"", "tag", "2"}
+	if err := run(testRemFile); err == nil {
+		t.Error("No error when tagging without tag.")
+	}
+
+	rem.read()
+	if rem.lines[0].tagList() != "list" || rem.lines[1].tagList() != "" {
+		t.Errorf("Wrong tags, got %s and %s", rem.lines[0].tagList(), rem.lines[1].tagList())
+	}
+}
+
 func TestRunPrintLine(t *testing.T) {
 	// create test file
 	rem := getTestRem(t)
@@ -189,7 +329,7 @@ func TestRunFilter(t *testing.T) {
 	w.Close()
 	out, _ := ioutil.ReadAll(r)
 	os.Stdout = rescueStdout
-	if string(out) != " 2  echo test\n" {
+	if string(out) != " 2  kft8sg  echo test\n" {
 		t.Errorf("Wrong filter output, got %s", out)
 	}
 }
@@ -213,7 +353,7 @@ func TestRunFilterFlag(t *testing.T) {
 	w.Close()
 	out, _ := ioutil.ReadAll(r)
 	os.Stdout = rescueStdout
-	if string(out) != " 2  echo test\n" {
+	if string(out) != " 2  kft8sg  echo test\n" {
 		t.Errorf("Wrong filter output, got %s", out)
 	}
 
@@ -251,6 +391,48 @@ func TestRunHereClear(t *testing.T) {
 
 }
 
+func TestRunClearUndo(t *testing.T) {
+	// create test file
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	rescueStdout := os.Stdout
+	os.Stdout, _ = os.Open(os.DevNull)
+	defer func() { os.Stdout = rescueStdout }()
+
+	// answer no
+	funcDefer, err := mockStdin(t, "n\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer funcDefer()
+	os.Args = []string{"", "clear"}
+	if err := run(testRemFile); err != nil {
+		t.Errorf("Error when not clearing, got %s", err)
+	}
+	if rem.read(); len(rem.lines) != 3 {
+		t.Errorf("Lines cleared without confirmation, got %d lines", len(rem.lines))
+	}
+
+	os.Args = []string{"", "-y", "clear"}
+	if err := run(testRemFile); err != nil {
+		t.Errorf("Error when clearing, got %s", err)
+	}
+	*yesFlag = false
+	if rem.read(); len(rem.lines) != 0 {
+		t.Errorf("Lines not cleared, got %d lines", len(rem.lines))
+	}
+
+	os.Args = []string{"", "undo"}
+	if err := run(testRemFile); err != nil {
+		t.Errorf("Error when undoing, got %s", err)
+	}
+	if rem.read(); len(rem.lines) != 3 {
+		t.Errorf("Clear not reverted, got %d lines", len(rem.lines))
+	}
+}
+
 func TestInitialHelp(t *testing.T) {
 	// create test file
 	rem := getTestEmptyRem(t)
@@ -282,6 +464,7 @@ func TestRunHelp(t *testing.T) {
 
 	helpBool := true
 	helpFlag = &helpBool
+	defer func() { helpBool = false }()
 
 	err := run(testRemFile)
 	if err != nil {
@@ -295,3 +478,88 @@ func TestRunHelp(t *testing.T) {
 		t.Error("Help not  correct!")
 	}
 }
+
+func TestRunAddLast(t *testing.T) {
+	rem := getTestEmptyRem(t)
+	defer removeRemFile(rem)
+	t.Setenv("SHELL", "/bin/zsh")
+	t.Setenv("HISTFILE", t.TempDir()+"/history")
+	ioutil.WriteFile(os.Getenv("HISTFILE"), []byte(": 1700000000:0;kubectl get pods -A\n"), 0600)
+
+	rescueStdout := os.Stdout
+	os.Stdout, _ = os.Open(os.DevNull)
+	os.Args = []string{"", "-t", "pods", "add", "--last"}
+	err := run(testRemFile)
+	os.Stdout = rescueStdout
+	*tagFlag = ""
+
+	if err != nil {
+		t.Fatalf("Error when adding last command, got %s", err)
+	}
+	rem.read()
+	if len(rem.lines) != 1 || rem.lines[0].cmd != "kubectl get pods -A" || rem.lines[0].tagList() != "pods" {
+		t.Errorf("Last command not added, got %d lines", len(rem.lines))
+	}
+}
+
+func TestRunPrintExpanded(t *testing.T) {
+	rem := getRem(t, "#ping#ping -c {{count:=1}} {{host}}\n")
+	defer removeRemFile(rem)
+	rem.read()
+
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	os.Args = []string{"", "echo", "ping", "host=example.org"}
+	err := run(testRemFile)
+
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+	os.Stdout = rescueStdout
+	if err != nil || string(out) != "ping -c 1 example.org\n" {
+		t.Errorf("Wrong expanded output, got %s %v", out, err)
+	}
+
+	os.Args = []string{"", "echo", "ping", "example.org"}
+	if err := run(testRemFile); err == nil {
+		t.Error("No error for argument without name.")
+	}
+}
+
+func TestRunPrintWithArgs(t *testing.T) {
+	rem := getRem(t, "#test#go test ./...\n")
+	defer removeRemFile(rem)
+	rem.read()
+
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	os.Args = []string{"", "echo", "test", "--", "-run", "TestFoo Bar", "-v"}
+	err := run(testRemFile)
+
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+	os.Stdout = rescueStdout
+	if err != nil || string(out) != "go test ./... -run 'TestFoo Bar' -v\n" {
+		t.Errorf("Wrong output with arguments, got %s %v", out, err)
+	}
+}
+
+func TestRunReadError(t *testing.T) {
+	rem := getRem(t, "{\"format\":\"rem\",\"version\":9}\n{\"cmd\":\"ls\"}\n")
+	defer removeRemFile(rem)
+	rem.read()
+
+	os.Args = []string{"", "echo", "0"}
+	if err := run(testRemFile); err == nil || !strings.Contains(err.Error(), "version 9 is not supported") {
+		t.Errorf("Wrong error for unsupported version, got %v", err)
+	}
+
+	rem = getRem(t, "{\"format\":\"rem\",\"version\":3}\n{\"cmd\":\"ls\"\n")
+	os.Args = []string{""}
+	if err := run(testRemFile); err == nil {
+		t.Error("No error for broken line.")
+	}
+}
@@ -0,0 +1,202 @@
+// rem - A tool to remember things on the command line.
+// Copyright (C) 2015 Martin Borho (martin@borho.net)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Settings read from the config file and REM_* environment variables.
+type Config struct {
+	GlobalFile string // path of the global rem file, default ~/<Filename>
+	Filename   string // name of local rem files
+	Editor     string // editor for edit, default $EDITOR or nano
+	Shell      string // shell executing commands, default the calling one
+	Color      string // auto, always or never
+	Confirm    bool   // ask before clearing a rem file
+	ListFormat string // format of listed lines, e.g. "{index}\t{id}\t{cmd}"
+	SharedPath string // ":" separated dirs or files with shared commands
+	ExecMode   string // exec replaces rem with the command, child waits for it
+	Timing     bool   // print exit status and wall time of commands
+}
+
+// Keys of the settings REM_ followed by the upper case key overrides,
+// $REM_SHARED_PATH adds to shared_path instead.
+var configKeys = []string{"global_file", "filename", "editor", "shell", "color", "confirm", "list_format", "exec_mode", "timing"}
+
+// The active configuration, loaded by main before running rem.
+var config = defaultConfig()
+
+// Returns the configuration used without config file.
+func defaultConfig() *Config {
+	return &Config{
+		Filename:   ".rem",
+		Color:      "auto",
+		Confirm:    true,
+		SharedPath: "/etc/rem.d",
+		ExecMode:   "exec",
+	}
+}
+
+// Returns the path of the config file, $XDG_CONFIG_HOME/rem/config.
+func configPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = expandHome("~/.config")
+	}
+	return path.Join(dir, "rem", "config")
+}
+
+// Returns the dir for data of rem, $XDG_DATA_HOME/rem.
+func dataDir() string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		dir = expandHome("~/.local/share")
+	}
+	return path.Join(dir, "rem")
+}
+
+// Reads the config file if one exists and applies REM_* environment
+// variables on top of it.
+func loadConfig() (*Config, error) {
+	c := defaultConfig()
+	file, err := os.Open(configPath())
+	if err == nil {
+		defer file.Close()
+		if err := c.parse(bufio.NewScanner(file), configPath()); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, key := range configKeys {
+		if value, ok := os.LookupEnv("REM_" + strings.ToUpper(key)); ok {
+			if err := c.set(key, value); err != nil {
+				return nil, fmt.Errorf("Invalid REM_%s: %s", strings.ToUpper(key), err)
+			}
+		}
+	}
+	return c, nil
+}
+
+// Reads "key = value" lines, empty lines and lines starting with # are
+// ignored. Values can be quoted to keep spaces or use escapes like \t.
+func (c *Config) parse(scanner *bufio.Scanner, name string) error {
+	for nr := 1; scanner.Scan(); nr++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("Invalid line %d in %s, expected key = value.", nr, name)
+		}
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+		if strings.HasPrefix(value, `"`) {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return fmt.Errorf("Invalid value in line %d in %s: %s", nr, name, err)
+			}
+			value = unquoted
+		}
+		if err := c.set(key, value); err != nil {
+			return fmt.Errorf("%s line %d: %s", name, nr, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// Sets a setting by key.
+func (c *Config) set(key, value string) error {
+	switch key {
+	case "global_file":
+		c.GlobalFile = value
+	case "filename":
+		if value == "" || strings.Contains(value, "/") {
+			return fmt.Errorf("Setting filename needs a file name, got %s.", value)
+		}
+		c.Filename = value
+	case "editor":
+		c.Editor = value
+	case "shell":
+		c.Shell = value
+	case "color":
+		if value != "auto" && value != "always" && value != "never" {
+			return fmt.Errorf("Setting color needs auto, always or never, got %s.", value)
+		}
+		c.Color = value
+	case "confirm":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("Setting confirm needs true or false, got %s.", value)
+		}
+		c.Confirm = b
+	case "list_format":
+		c.ListFormat = value
+	case "shared_path":
+		c.SharedPath = value
+	case "exec_mode":
+		if value != "exec" && value != "child" {
+			return fmt.Errorf("Setting exec_mode needs exec or child, got %s.", value)
+		}
+		c.ExecMode = value
+	case "timing":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("Setting timing needs true or false, got %s.", value)
+		}
+		c.Timing = b
+	default:
+		return fmt.Errorf("Unknown setting %s.", key)
+	}
+	return nil
+}
+
+// ANSI colors used in listings.
+const (
+	colorID  = "2"
+	colorTag = "36"
+)
+
+// Returns str in the given ANSI color if listings get colored.
+func paint(color, str string) string {
+	if !config.colored() {
+		return str
+	}
+	return "\033[" + color + "m" + str + "\033[0m"
+}
+
+// Returns whether listings get colored.
+func (c *Config) colored() bool {
+	switch c.Color {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return isTerminal(os.Stdout)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	os.Mkdir(path.Join(dir, "rem"), 0755)
+	if err := ioutil.WriteFile(path.Join(dir, "rem", "config"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	c, err := loadConfig()
+	if err != nil {
+		t.Fatalf("Error loading config, got %s", err)
+	}
+	if *c != *defaultConfig() {
+		t.Errorf("Wrong default config, got %+v", c)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	writeConfig(t, `# rem settings
+global_file = ~/notes/commands.rem
+filename = .commands
+editor = vim -u NONE
+shell=/bin/zsh
+color = never
+confirm = false
+list_format = "{index}\t{cmd}"
+exec_mode = child
+timing = true
+`)
+	t.Setenv("REM_EDITOR", "emacs")
+	c, err := loadConfig()
+	if err != nil {
+		t.Fatalf("Error loading config, got %s", err)
+	}
+	if c.GlobalFile != "~/notes/commands.rem" || c.Filename != ".commands" || c.Shell != "/bin/zsh" {
+		t.Errorf("Wrong paths, got %+v", c)
+	}
+	if c.Editor != "emacs" {
+		t.Errorf("Editor not overridden by environment, got %s", c.Editor)
+	}
+	if c.Color != "never" || c.Confirm || c.ListFormat != "{index}\t{cmd}" || c.ExecMode != "child" || !c.Timing {
+		t.Errorf("Wrong settings, got %+v", c)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	for _, content := range []string{"colour = never\n", "color = blue\n", "confirm = maybe\n", "filename\n", "filename = a/b\n", "exec_mode = fork\n", `editor = "vim` + "\n"} {
+		writeConfig(t, content)
+		if _, err := loadConfig(); err == nil {
+			t.Errorf("No error for %s", content)
+		}
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("REM_COLOR", "blue")
+	if _, err := loadConfig(); err == nil {
+		t.Error("No error for invalid environment variable.")
+	}
+}
+
+func TestPrintListFormat(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config = defaultConfig()
+	config.ListFormat = "{index}: {cmd} [{tags}]"
+
+	var buf bytes.Buffer
+	l := &Line{id: "abc234", cmd: "ls -la", tags: []string{"foo", "bar"}}
+	l.print(&buf, 3, true)
+	if buf.String() != "3: ls -la [foo,bar]\n" {
+		t.Errorf("Wrong line output, got %s", buf.String())
+	}
+}
+
+func TestPaint(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config = defaultConfig()
+
+	config.Color = "always"
+	if paint(colorTag, "foo") != "\033[36mfoo\033[0m" {
+		t.Errorf("Not colored, got %q", paint(colorTag, "foo"))
+	}
+	config.Color = "never"
+	if paint(colorTag, "foo") != "foo" {
+		t.Errorf("Colored, got %q", paint(colorTag, "foo"))
+	}
+}
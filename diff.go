@@ -0,0 +1,69 @@
+// rem - A tool to remember things on the command line.
+// Copyright (C) 2015 Martin Borho (martin@borho.net)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"strings"
+)
+
+// Returns a line based diff of two texts, unchanged lines are prefixed
+// with "  ", removed ones with "- " and added ones with "+ ".
+func diffLines(a, b string) []string {
+	old := strings.Split(a, "\n")
+	new := strings.Split(b, "\n")
+
+	// length of the longest common subsequence for all suffixes
+	lcs := make([][]int, len(old)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	diff := []string{}
+	i, j := 0, 0
+	for i < len(old) && j < len(new) {
+		switch {
+		case old[i] == new[j]:
+			diff = append(diff, "  "+old[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "- "+old[i])
+			i++
+		default:
+			diff = append(diff, "+ "+new[j])
+			j++
+		}
+	}
+	for ; i < len(old); i++ {
+		diff = append(diff, "- "+old[i])
+	}
+	for ; j < len(new); j++ {
+		diff = append(diff, "+ "+new[j])
+	}
+	return diff
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected string
+	}{
+		{"ls -la", "ls -la", "  ls -la"},
+		{"ls -la", "ls -lah", "- ls -la|+ ls -lah"},
+		{"a\nb\nc", "a\nc\nd", "  a|- b|  c|+ d"},
+		{"", "a", "- |+ a"},
+		{"for x in 1 2; do\n  echo $x\ndone", "for x in 1 2 3; do\n  echo $x\ndone",
+			"- for x in 1 2; do|+ for x in 1 2 3; do|    echo $x|  done"},
+	}
+	for _, tc := range cases {
+		diff := strings.Join(diffLines(tc.a, tc.b), "|")
+		if diff != tc.expected {
+			t.Errorf("Wrong diff for %q and %q, want %q, got %q", tc.a, tc.b, tc.expected, diff)
+		}
+	}
+}
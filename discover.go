@@ -0,0 +1,283 @@
+// rem - A tool to remember things on the command line.
+// Copyright (C) 2015 Martin Borho (martin@borho.net)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A task found in a Makefile, package.json or justfile.
+type task struct {
+	tag         string
+	cmd         string
+	description string
+}
+
+// Prefixes of the tags of discovered tasks, they mark lines kept in sync.
+var taskPrefixes = []string{"make:", "npm:", "just:"}
+
+var (
+	makeTarget   = regexp.MustCompile(`^([A-Za-z0-9_][A-Za-z0-9_./-]*(?:\s+[A-Za-z0-9_][A-Za-z0-9_./-]*)*)\s*::?(.*)$`)
+	justRecipe   = regexp.MustCompile(`^@?([A-Za-z0-9][A-Za-z0-9_-]*)(\s[^:]*)?:(.*)$`)
+	helpComment  = regexp.MustCompile(`##\s*(.*)$`)
+	justKeywords = regexp.MustCompile(`^(set|alias|export|import|mod)\s`)
+)
+
+// Returns the comment lines collected above a target as description.
+func commentText(comments []string) string {
+	return strings.TrimSpace(strings.Join(comments, " "))
+}
+
+// Parses the targets of a Makefile with the comments above them or
+// a "## help" comment behind them as description.
+func parseMakefile(data []byte) []task {
+	tasks := []task{}
+	seen := map[string]bool{}
+	comments := []string{}
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			comments = append(comments, strings.TrimSpace(strings.TrimLeft(line, "#")))
+			continue
+		}
+		match := makeTarget.FindStringSubmatch(line)
+		if match == nil || strings.HasPrefix(match[2], "=") {
+			// recipe line, variable or empty line
+			if !strings.HasPrefix(line, "\t") {
+				comments = []string{}
+			}
+			continue
+		}
+		description := commentText(comments)
+		if help := helpComment.FindStringSubmatch(match[2]); help != nil {
+			description = strings.TrimSpace(help[1])
+		}
+		for _, target := range strings.Fields(match[1]) {
+			if seen[target] || strings.ContainsAny(target, "%") {
+				continue
+			}
+			seen[target] = true
+			tasks = append(tasks, task{tag: "make:" + target, cmd: "make " + target, description: description})
+		}
+		comments = []string{}
+	}
+	return tasks
+}
+
+// Parses the scripts of a package.json, sorted by name.
+func parsePackageJSON(data []byte) ([]task, error) {
+	pkg := struct {
+		Scripts map[string]string `json:"scripts"`
+	}{}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("Invalid package.json: %s", err)
+	}
+	names := []string{}
+	for name := range pkg.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tasks := []task{}
+	for _, name := range names {
+		tasks = append(tasks, task{tag: "npm:" + name, cmd: "npm run " + name, description: pkg.Scripts[name]})
+	}
+	return tasks, nil
+}
+
+// Parses the recipes of a justfile with the comments above them as
+// description, private recipes are left out.
+func parseJustfile(data []byte) []task {
+	tasks := []task{}
+	comments := []string{}
+	private := false
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "#"):
+			comments = append(comments, strings.TrimSpace(strings.TrimLeft(line, "#")))
+			continue
+		case strings.HasPrefix(line, "["):
+			// attributes of the next recipe
+			private = private || strings.Contains(line, "private")
+			continue
+		}
+		match := justRecipe.FindStringSubmatch(line)
+		if match != nil && !strings.HasPrefix(match[3], "=") && !justKeywords.MatchString(line) &&
+			!private && !strings.HasPrefix(match[1], "_") {
+			tasks = append(tasks, task{tag: "just:" + match[1], cmd: "just " + match[1], description: commentText(comments)})
+		}
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			comments = []string{}
+			private = false
+		}
+	}
+	return tasks
+}
+
+// Returns the tasks of all task files in dir.
+func discoverTasks(dir string) ([]task, error) {
+	tasks := []task{}
+	read := func(names ...string) []byte {
+		for _, name := range names {
+			if data, err := ioutil.ReadFile(path.Join(dir, name)); err == nil {
+				return data
+			}
+		}
+		return nil
+	}
+	if data := read("GNUmakefile", "makefile", "Makefile"); data != nil {
+		tasks = append(tasks, parseMakefile(data)...)
+	}
+	if data := read("package.json"); data != nil {
+		npm, err := parsePackageJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, npm...)
+	}
+	if data := read("justfile", "Justfile", ".justfile"); data != nil {
+		tasks = append(tasks, parseJustfile(data)...)
+	}
+	return tasks, nil
+}
+
+// Returns the tag of a discovered task the line has, if any.
+func (l *Line) taskTag() string {
+	for _, tag := range l.tags {
+		for _, prefix := range taskPrefixes {
+			if strings.HasPrefix(tag, prefix) {
+				return tag
+			}
+		}
+	}
+	return ""
+}
+
+func (r *Rem) discover(all bool) error {
+	// Updates lines of tasks found before in the directory of the rem
+	// file, removes the ones of tasks which are gone and offers new
+	// tasks to add. The global file and notebooks are shared by several
+	// projects, syncing would remove the tasks of the other ones.
+	if err := r.read(); err != nil {
+		return err
+	}
+	globalPath, _ := r.globalPath()
+	if r.filepath == globalPath || path.Dir(r.filepath) == notebookDir() {
+		return errors.New("Tasks are discovered into the rem file of a project, create one with \"rem here\" first.")
+	}
+	tasks, err := discoverTasks(path.Dir(r.filepath))
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		return fmt.Errorf("No tasks found in Makefile, package.json or justfile in %s.", path.Dir(r.filepath))
+	}
+	newTasks := []task{}
+	for _, t := range tasks {
+		if _, err := r.getIndexByTag(t.tag); err == errTagNotFound {
+			newTasks = append(newTasks, t)
+		}
+	}
+
+	chosen := newTasks
+	if len(newTasks) > 0 && !all {
+		w := r.getTabWriter()
+		for n, t := range newTasks {
+			fmt.Fprintf(w, " %d\t%s\t%s\t%s\n", n+1, t.tag, t.cmd, t.description)
+		}
+		w.Flush()
+		fmt.Print("Add which tasks? (e.g. 1 3-5, a for all, empty for none) ")
+		input, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if input = strings.TrimSpace(input); input != "a" {
+			picks, err := parseSelection(input, len(newTasks))
+			if err != nil {
+				return err
+			}
+			chosen = []task{}
+			for _, p := range picks {
+				chosen = append(chosen, newTasks[p.index])
+			}
+		}
+	}
+
+	added, updated, removed := 0, 0, 0
+	author := currentAuthor()
+	err = r.update(func() error {
+		byTag := map[string]task{}
+		for _, t := range tasks {
+			byTag[t.tag] = t
+		}
+		now := time.Now()
+		for i := len(r.lines) - 1; i >= 0; i-- {
+			line := r.lines[i]
+			// only lines added by discover, not ones with a task tag by hand
+			tag := line.taskTag()
+			if tag == "" || !line.discovered {
+				continue
+			}
+			t, ok := byTag[tag]
+			if !ok {
+				r.remember("sync", i)
+				r.lines = append(r.lines[:i:i], r.lines[i+1:]...)
+				removed++
+			} else if line.cmd != t.cmd || line.description != t.description {
+				r.remember("sync", i)
+				if line.cmd != t.cmd {
+					line.history = append(line.history, revision{Cmd: line.cmd, Modified: line.modified})
+				}
+				line.cmd = t.cmd
+				line.description = t.description
+				line.modified = now
+				updated++
+			}
+		}
+		for _, t := range chosen {
+			if _, err := r.getIndexByTag(t.tag); err != errTagNotFound {
+				continue
+			}
+			r.lines = append(r.lines, &Line{
+				id:          r.newID(),
+				cmd:         t.cmd,
+				tags:        []string{t.tag},
+				description: t.description,
+				created:     now,
+				modified:    now,
+				author:      author,
+				discovered:  true,
+			})
+			added++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Added %d tasks, updated %d, removed %d.\n", added, updated, removed)
+	return nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestParseMakefile(t *testing.T) {
+	data := `VERSION := 1.0
+CC = gcc
+.PHONY: build test
+
+# Builds the binary
+# for the current platform
+build: deps
+	go build -o rem .
+
+deps:
+	go mod download
+
+test lint: ## runs the checks
+	go test ./...
+
+%.o: %.c
+	$(CC) -c $<
+`
+	tasks := parseMakefile([]byte(data))
+	expected := []task{
+		{"make:build", "make build", "Builds the binary for the current platform"},
+		{"make:deps", "make deps", ""},
+		{"make:test", "make test", "runs the checks"},
+		{"make:lint", "make lint", "runs the checks"},
+	}
+	if len(tasks) != len(expected) {
+		t.Fatalf("Wrong tasks, got %+v", tasks)
+	}
+	for i := range expected {
+		if tasks[i] != expected[i] {
+			t.Errorf("Wrong task %d, got %+v", i, tasks[i])
+		}
+	}
+}
+
+func TestParsePackageJSON(t *testing.T) {
+	tasks, err := parsePackageJSON([]byte(`{"name": "app", "scripts": {"test": "jest", "build": "tsc -p ."}}`))
+	if err != nil {
+		t.Fatalf("Error parsing package.json, got %s", err)
+	}
+	if len(tasks) != 2 || tasks[0] != (task{"npm:build", "npm run build", "tsc -p ."}) || tasks[1].tag != "npm:test" {
+		t.Errorf("Wrong tasks, got %+v", tasks)
+	}
+	if _, err := parsePackageJSON([]byte("{")); err == nil {
+		t.Error("No error for invalid package.json.")
+	}
+}
+
+func TestParseJustfile(t *testing.T) {
+	data := `set shell := ["bash", "-c"]
+version := "1.0"
+alias b := build
+
+# build the app
+build target="release": _prepare
+    cargo build --{{target}}
+
+[private]
+helper:
+    echo helper
+
+_prepare:
+    mkdir -p out
+
+@serve port='8080':
+    python -m http.server {{port}}
+`
+	tasks := parseJustfile([]byte(data))
+	if len(tasks) != 2 {
+		t.Fatalf("Wrong tasks, got %+v", tasks)
+	}
+	if tasks[0] != (task{"just:build", "just build", "build the app"}) || tasks[1] != (task{"just:serve", "just serve", ""}) {
+		t.Errorf("Wrong tasks, got %+v", tasks)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	rem := (&Rem{}).newLayer(path.Join(dir, ".rem"))
+	ioutil.WriteFile(rem.path, []byte("ls\nls -la\necho test\n"), 0644)
+	ioutil.WriteFile(path.Join(dir, "Makefile"), []byte("build:\n\tgo build\n\n# run tests\ntest:\n\tgo test\n"), 0644)
+	ioutil.WriteFile(path.Join(dir, "package.json"), []byte(`{"scripts": {"lint": "eslint ."}}`), 0644)
+
+	rescueStdout := os.Stdout
+	os.Stdout, _ = os.Open(os.DevNull)
+	defer func() { os.Stdout = rescueStdout }()
+
+	funcDefer, err := mockStdin(t, "1 3\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rem.discover(false)
+	funcDefer()
+	if err != nil {
+		t.Fatalf("Error discovering tasks, got %s", err)
+	}
+	rem.read()
+	if len(rem.lines) != 5 || rem.lines[3].tagList() != "make:build" || rem.lines[4].cmd != "npm run lint" {
+		t.Fatalf("Wrong tasks added, got %d lines", len(rem.lines))
+	}
+
+	// re-run syncs changed and removed tasks
+	ioutil.WriteFile(path.Join(dir, "Makefile"), []byte("# build it\nbuild:\n\tgo build\n"), 0644)
+	ioutil.WriteFile(path.Join(dir, "package.json"), []byte(`{"scripts": {}}`), 0644)
+	if err := rem.discover(true); err != nil {
+		t.Fatalf("Error syncing tasks, got %s", err)
+	}
+	rem.read()
+	if len(rem.lines) != 4 || rem.lines[3].description != "build it" {
+		t.Fatalf("Tasks not synced, got %d lines", len(rem.lines))
+	}
+	trash, _ := rem.trash()
+	if len(trash) != 1 || trash[0].line.cmd != "npm run lint" {
+		t.Errorf("Removed task not in trash, got %d", len(trash))
+	}
+}
+
+func TestDiscoverKeepsOwnLines(t *testing.T) {
+	// lines tagged like tasks by hand aren't synced
+	dir := t.TempDir()
+	rem := (&Rem{}).newLayer(path.Join(dir, ".rem"))
+	ioutil.WriteFile(rem.path, []byte("#make:deploy#./deploy.sh prod\n#make:build#go build -v\n"), 0644)
+	ioutil.WriteFile(path.Join(dir, "Makefile"), []byte("build:\n\tgo build\n\ntest:\n\tgo test\n"), 0644)
+
+	rescueStdout := os.Stdout
+	os.Stdout, _ = os.Open(os.DevNull)
+	defer func() { os.Stdout = rescueStdout }()
+
+	for run := 0; run < 2; run++ {
+		if err := rem.discover(true); err != nil {
+			t.Fatalf("Error discovering tasks, got %s", err)
+		}
+	}
+	rem.read()
+	if len(rem.lines) != 3 || rem.lines[0].cmd != "./deploy.sh prod" || rem.lines[1].cmd != "go build -v" {
+		t.Fatalf("Own lines synced, got %d lines", len(rem.lines))
+	}
+	if rem.lines[0].discovered || !rem.lines[2].discovered || rem.lines[2].tagList() != "make:test" {
+		t.Errorf("Wrong discovered marker, got %+v", rem.lines[2])
+	}
+}
+
+func TestDiscoverNothing(t *testing.T) {
+	rem := (&Rem{}).newLayer(path.Join(t.TempDir(), ".rem"))
+	if err := rem.discover(true); err == nil {
+		t.Error("No error without task files.")
+	}
+}
+
+func TestDiscoverShared(t *testing.T) {
+	// the global file and notebooks are used for several projects
+	dir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dir)
+	useShell(t, "")
+	config.GlobalFile = path.Join(dir, "global.rem")
+	ioutil.WriteFile(path.Join(dir, "Makefile"), []byte("build:\n\tgo build\n"), 0644)
+	ioutil.WriteFile(config.GlobalFile, []byte("#make:test#make test\n"), 0644)
+
+	global := &Rem{File: File{filename: testRemFile, global: true}}
+	if err := global.discover(true); err == nil {
+		t.Error("No error for global rem file.")
+	}
+	global.read()
+	if len(global.lines) != 1 {
+		t.Errorf("Global rem file synced, got %d lines", len(global.lines))
+	}
+	notebook := getNotebook(t, "ops", "")
+	if err := notebook.discover(true); err == nil {
+		t.Error("No error for notebook.")
+	}
+}
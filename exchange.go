@@ -0,0 +1,587 @@
+// rem - A tool to remember things on the command line.
+// Copyright (C) 2015 Martin Borho (martin@borho.net)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Formats for export and import.
+var exchangeFormats = []string{"json", "yaml", "csv", "md"}
+
+// Returns the format for a file by its extension.
+func formatFromPath(file string) (string, error) {
+	switch strings.ToLower(path.Ext(file)) {
+	case ".json":
+		return "json", nil
+	case ".yaml", ".yml":
+		return "yaml", nil
+	case ".csv":
+		return "csv", nil
+	case ".md", ".markdown":
+		return "md", nil
+	}
+	return "", fmt.Errorf("Unknown format of %s, use --format with one of %s.", file, strings.Join(exchangeFormats, ", "))
+}
+
+// Encodes lines in the given format.
+func exportLines(lines []*Line, format string) ([]byte, error) {
+	switch format {
+	case "json":
+		return encodeJSON(lines)
+	case "yaml":
+		return encodeYAML(lines), nil
+	case "csv":
+		return encodeCSV(lines)
+	case "md":
+		return encodeMarkdown(lines), nil
+	}
+	return nil, fmt.Errorf("Unknown format %s, use one of %s.", format, strings.Join(exchangeFormats, ", "))
+}
+
+// Decodes lines in the given format.
+func importLines(data []byte, format string) ([]*Line, error) {
+	switch format {
+	case "json":
+		return decodeJSON(data)
+	case "yaml":
+		return decodeYAML(data)
+	case "csv":
+		return decodeCSV(data)
+	case "md":
+		return decodeMarkdown(data)
+	}
+	return nil, fmt.Errorf("Unknown format %s, use one of %s.", format, strings.Join(exchangeFormats, ", "))
+}
+
+// Formats a time for export, empty if not set.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Parses an exported time, empty means not set.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid time %s.", value)
+	}
+	return t, nil
+}
+
+// Sets a field of line by its exported name.
+func (l *Line) setField(key, value string) error {
+	var err error
+	switch key {
+	case "id":
+		l.id = value
+	case "tags":
+		l.tags = parseTags(value)
+	case "cmd":
+		l.cmd = value
+	case "description":
+		l.description = value
+	case "author":
+		l.author = value
+	case "created":
+		l.created, err = parseTime(value)
+	case "modified":
+		l.modified, err = parseTime(value)
+	default:
+		err = fmt.Errorf("Unknown field %s.", key)
+	}
+	return err
+}
+
+func encodeJSON(lines []*Line) ([]byte, error) {
+	// all fields including the history
+	records := []*record{}
+	for _, l := range lines {
+		records = append(records, l.toRecord())
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(records)
+	return buf.Bytes(), err
+}
+
+func decodeJSON(data []byte) ([]*Line, error) {
+	records := []*record{}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("Invalid JSON: %s", err)
+	}
+	lines := []*Line{}
+	for _, rec := range records {
+		l := &Line{}
+		l.fromRecord(rec)
+		lines = append(lines, l)
+	}
+	return lines, nil
+}
+
+// Returns str as YAML scalar, multi-line strings as literal block.
+func yamlString(str string, indent string) string {
+	if strings.Contains(str, "\n") && !strings.HasPrefix(str, " ") && !strings.HasSuffix(str, "\n") {
+		return "|-\n" + indent + strings.ReplaceAll(str, "\n", "\n"+indent)
+	}
+	quoted, _ := json.Marshal(str)
+	return string(quoted)
+}
+
+func encodeYAML(lines []*Line) []byte {
+	var buf bytes.Buffer
+	for _, l := range lines {
+		fmt.Fprintf(&buf, "- id: %s\n", l.id)
+		if len(l.tags) > 0 {
+			tags := []string{}
+			for _, tag := range l.tags {
+				tags = append(tags, yamlString(tag, ""))
+			}
+			fmt.Fprintf(&buf, "  tags: [%s]\n", strings.Join(tags, ", "))
+		}
+		fmt.Fprintf(&buf, "  cmd: %s\n", yamlString(l.cmd, "    "))
+		for _, field := range [][2]string{
+			{"description", l.description},
+			{"author", l.author},
+		} {
+			if field[1] != "" {
+				fmt.Fprintf(&buf, "  %s: %s\n", field[0], yamlString(field[1], "    "))
+			}
+		}
+		for _, field := range [][2]string{
+			{"created", formatTime(l.created)},
+			{"modified", formatTime(l.modified)},
+		} {
+			if field[1] != "" {
+				fmt.Fprintf(&buf, "  %s: %s\n", field[0], field[1])
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+// Returns the value of a YAML scalar, quoted or plain.
+func yamlScalar(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		var str string
+		if err := json.Unmarshal([]byte(value), &str); err != nil {
+			return "", fmt.Errorf("Invalid quoted string %s.", value)
+		}
+		return str, nil
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", fmt.Errorf("Invalid quoted string %s.", value)
+		}
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value), nil
+}
+
+// Returns the items of a YAML flow sequence like [a, "b"].
+func yamlFlowList(value string) ([]string, error) {
+	inner := strings.TrimSpace(value[1 : len(value)-1])
+	items := []string{}
+	if inner == "" {
+		return items, nil
+	}
+	for _, item := range strings.Split(inner, ",") {
+		str, err := yamlScalar(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, str)
+	}
+	return items, nil
+}
+
+// Decodes a list of entries in YAML, as written by encodeYAML or by
+// hand: plain or quoted scalars, literal blocks and lists of tags.
+func decodeYAML(data []byte) ([]*Line, error) {
+	lines := []*Line{}
+	rows := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	indentOf := func(row string) int {
+		return len(row) - len(strings.TrimLeft(row, " "))
+	}
+	var current *Line
+	for nr := 0; nr < len(rows); nr++ {
+		row := rows[nr]
+		trimmed := strings.TrimSpace(row)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		indent := indentOf(row)
+		if strings.HasPrefix(row, "- ") {
+			current = &Line{}
+			lines = append(lines, current)
+			row = "  " + row[2:]
+			indent = 2
+		} else if current == nil || indent == 0 {
+			return nil, fmt.Errorf("Invalid YAML in line %d, expected a list of entries.", nr+1)
+		}
+		parts := strings.SplitN(strings.TrimSpace(row), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid YAML in line %d, expected key: value.", nr+1)
+		}
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+
+		// values spread over the following, deeper indented rows
+		block := []string{}
+		for nr+1 < len(rows) && (strings.TrimSpace(rows[nr+1]) == "" || indentOf(rows[nr+1]) > indent) {
+			nr++
+			block = append(block, rows[nr])
+		}
+		for len(block) > 0 && strings.TrimSpace(block[len(block)-1]) == "" {
+			block = block[:len(block)-1]
+		}
+
+		var err error
+		switch {
+		case strings.HasPrefix(value, "|"):
+			blockIndent := 0
+			if len(block) > 0 {
+				blockIndent = indentOf(block[0])
+			}
+			for i, b := range block {
+				if len(b) >= blockIndent {
+					block[i] = b[blockIndent:]
+				}
+			}
+			err = current.setField(key, strings.Join(block, "\n"))
+		case key == "tags" && strings.HasPrefix(value, "["):
+			var tags []string
+			if tags, err = yamlFlowList(value); err == nil {
+				current.tags = tags
+			}
+		case key == "tags" && value == "":
+			current.tags = []string{}
+			for _, b := range block {
+				var tag string
+				if tag, err = yamlScalar(strings.TrimPrefix(strings.TrimSpace(b), "- ")); err != nil {
+					break
+				}
+				current.tags = append(current.tags, tag)
+			}
+		default:
+			var str string
+			if str, err = yamlScalar(value); err == nil {
+				err = current.setField(key, str)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid YAML in line %d: %s", nr+1, err)
+		}
+	}
+	return lines, nil
+}
+
+// Columns of exported CSV files.
+var csvColumns = []string{"id", "tags", "cmd", "description", "author", "created", "modified"}
+
+func encodeCSV(lines []*Line) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(csvColumns)
+	for _, l := range lines {
+		w.Write([]string{l.id, l.tagList(), l.cmd, l.description, l.author, formatTime(l.created), formatTime(l.modified)})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// Decodes CSV with a header row naming the columns, only cmd is needed.
+func decodeCSV(data []byte) ([]*Line, error) {
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid CSV: %s", err)
+	}
+	lines := []*Line{}
+	if len(rows) == 0 {
+		return lines, nil
+	}
+	columns := rows[0]
+	hasCmd := false
+	for _, column := range columns {
+		hasCmd = hasCmd || column == "cmd"
+	}
+	if !hasCmd {
+		return nil, errors.New("Invalid CSV, no cmd column in header.")
+	}
+	for nr, row := range rows[1:] {
+		l := &Line{}
+		for i, value := range row {
+			if err := l.setField(columns[i], value); err != nil {
+				return nil, fmt.Errorf("Invalid CSV in row %d: %s", nr+2, err)
+			}
+		}
+		lines = append(lines, l)
+	}
+	return lines, nil
+}
+
+// Returns a fence of backticks longer than any run of backticks in str.
+func codeFence(str string) string {
+	longest, run := 0, 0
+	for _, c := range str {
+		if c == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+func encodeMarkdown(lines []*Line) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Commands\n")
+	for _, l := range lines {
+		title := l.id
+		if len(l.tags) > 0 {
+			title = l.tags[0]
+		}
+		fmt.Fprintf(&buf, "\n## %s\n\n", title)
+		if l.description != "" {
+			// quoted, its lines can't be taken as fields or titles
+			for _, row := range strings.Split(l.description, "\n") {
+				fmt.Fprintln(&buf, strings.TrimRight("> "+row, " "))
+			}
+			buf.WriteString("\n")
+		}
+		fence := codeFence(l.cmd)
+		fmt.Fprintf(&buf, "%ssh\n%s\n%s\n\n", fence, l.cmd, fence)
+		fmt.Fprintf(&buf, "- id: `%s`\n", l.id)
+		if len(l.tags) > 0 {
+			fmt.Fprintf(&buf, "- tags: `%s`\n", strings.Join(l.tags, "`, `"))
+		}
+		for _, field := range [][2]string{
+			{"author", l.author},
+			{"created", formatTime(l.created)},
+			{"modified", formatTime(l.modified)},
+		} {
+			if field[1] != "" {
+				fmt.Fprintf(&buf, "- %s: %s\n", field[0], field[1])
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+// Decodes markdown as written by encodeMarkdown, every "## " section
+// is an entry with its command in a code block and its description
+// quoted or as plain text before it.
+func decodeMarkdown(data []byte) ([]*Line, error) {
+	lines := []*Line{}
+	var current *Line
+	description := []string{}
+	fence := ""
+	code := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for nr := 1; scanner.Scan(); nr++ {
+		row := strings.TrimRight(scanner.Text(), "\r")
+		switch {
+		case fence != "":
+			// inside the code block
+			if strings.TrimSpace(row) == fence {
+				current.cmd = strings.Join(code, "\n")
+				fence = ""
+				continue
+			}
+			code = append(code, row)
+		case strings.HasPrefix(row, "## "):
+			if current != nil {
+				current.description = strings.TrimSpace(strings.Join(description, "\n"))
+			}
+			current = &Line{}
+			lines = append(lines, current)
+			description = []string{}
+		case current == nil:
+			// title and text before the first entry
+		case current.cmd == "" && strings.HasPrefix(row, ">"):
+			description = append(description, strings.TrimPrefix(row[1:], " "))
+		case strings.HasPrefix(row, "```"):
+			fence = strings.TrimRight(row, "abcdefghijklmnopqrstuvwxyz")
+			code = []string{}
+		case strings.HasPrefix(row, "- ") && strings.Contains(row, ": "):
+			parts := strings.SplitN(row[2:], ": ", 2)
+			value := strings.TrimSpace(parts[1])
+			if parts[0] == "tags" {
+				value = strings.NewReplacer("`, `", ",", "`", "").Replace(value)
+			} else {
+				value = strings.Trim(value, "`")
+			}
+			if err := current.setField(parts[0], value); err != nil {
+				return nil, fmt.Errorf("Invalid markdown in line %d: %s", nr, err)
+			}
+		case current.cmd == "":
+			description = append(description, row)
+		}
+	}
+	if fence != "" {
+		return nil, errors.New("Invalid markdown, code block not closed.")
+	}
+	if current != nil {
+		current.description = strings.TrimSpace(strings.Join(description, "\n"))
+	}
+	return lines, nil
+}
+
+func (r *Rem) export(format, output string) error {
+	// Writes all lines in the given format to output or stdout.
+	data, err := exportLines(r.lines, format)
+	if err != nil {
+		return err
+	}
+	if output == "" || output == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return ioutil.WriteFile(output, data, 0644)
+}
+
+// How imported lines are merged with existing ones.
+type importOptions struct {
+	skipDuplicates bool // skip commands which exist already
+	overwriteTags  bool // take used tags away from existing lines
+	renumber       bool // give all imported lines new ids
+}
+
+func (r *Rem) importFile(file, format string, opts importOptions) error {
+	// Adds the lines of an exported file, stdin for "-".
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+	lines, err := importLines(data, format)
+	if err != nil {
+		return err
+	}
+	author := currentAuthor()
+	for _, l := range lines {
+		if l.author == "" {
+			l.author = author
+		}
+	}
+
+	added, skipped := 0, 0
+	err = r.update(func() error {
+		for nr, l := range lines {
+			if err := r.mergeLine(l, opts); err != nil {
+				return fmt.Errorf("Entry %d: %s", nr+1, err)
+			}
+			if l.id == "" {
+				skipped++
+			} else {
+				added++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d commands, skipped %d.\n", added, skipped)
+	return nil
+}
+
+func (r *Rem) mergeLine(l *Line, opts importOptions) error {
+	// Appends an imported line, skipped duplicates are marked with an
+	// empty id.
+	if strings.TrimSpace(l.cmd) == "" {
+		return errors.New("No command given.")
+	}
+	if opts.skipDuplicates {
+		for _, line := range r.lines {
+			if line.cmd == l.cmd {
+				l.id = ""
+				return nil
+			}
+		}
+	}
+	for _, tag := range l.tags {
+		if err := checkTag(tag); err != nil {
+			return err
+		}
+		for i, other := range r.lines {
+			if !other.hasTag(tag) {
+				continue
+			}
+			if !opts.overwriteTags {
+				return fmt.Errorf("Tag %s is already used by line %d, use --overwrite-tags to move it.", tag, i)
+			}
+			r.remember("import", i)
+			other.removeTag(tag)
+		}
+	}
+	if _, err := r.getIndexByID(l.id); opts.renumber || !validID(l.id) || err == nil {
+		l.id = r.newID()
+	}
+	if l.created.IsZero() {
+		l.created = time.Now()
+	}
+	if l.modified.IsZero() {
+		l.modified = l.created
+	}
+	r.lines = append(r.lines, l)
+	return nil
+}
+
+// Checks if id could have been generated by rem.
+func validID(id string) bool {
+	if len(id) != 6 || !strings.ContainsRune(idChars[:idLetters], rune(id[0])) {
+		return false
+	}
+	for _, c := range id {
+		if !strings.ContainsRune(idChars, c) {
+			return false
+		}
+	}
+	return true
+}
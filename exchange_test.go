@@ -0,0 +1,188 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getExchangeLines() []*Line {
+	created := time.Date(2023, 4, 1, 12, 0, 0, 0, time.UTC)
+	modified := time.Date(2023, 4, 2, 8, 30, 0, 0, time.UTC)
+	return []*Line{
+		{id: "qebpwy", cmd: "ls"},
+		{id: "rwz6fj", cmd: `grep -r "foo, bar" . | wc -l # count`, tags: []string{"count", "grep"},
+			description: "counts 'foo, bar'", author: "Martin", created: created, modified: modified},
+		{id: "kft8sg", cmd: "for f in *; do\n  echo \"$f\"\ndone", tags: []string{"loop"}, description: "multi\n\n- note: keep\n## not a title\n> quoted"},
+		{id: "x7fmqa", cmd: "echo '```'", created: created, modified: created},
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	for _, format := range exchangeFormats {
+		data, err := exportLines(getExchangeLines(), format)
+		if err != nil {
+			t.Fatalf("Error exporting %s, got %s", format, err)
+		}
+		lines, err := importLines(data, format)
+		if err != nil {
+			t.Fatalf("Error importing %s, got %s\n%s", format, err, data)
+		}
+		expected := getExchangeLines()
+		if len(lines) != len(expected) {
+			t.Fatalf("Wrong number of lines in %s, got %d\n%s", format, len(lines), data)
+		}
+		for i, l := range lines {
+			e := expected[i]
+			if l.id != e.id || l.cmd != e.cmd || l.tagList() != e.tagList() || l.description != e.description ||
+				l.author != e.author || !l.created.Equal(e.created) || !l.modified.Equal(e.modified) {
+				t.Errorf("Wrong line %d in %s, got %+v\n%s", i, format, l, data)
+			}
+		}
+	}
+}
+
+func TestExportUnknownFormat(t *testing.T) {
+	if _, err := exportLines(getExchangeLines(), "xml"); err == nil {
+		t.Error("No error for unknown format.")
+	}
+	if _, err := importLines([]byte{}, "xml"); err == nil {
+		t.Error("No error for unknown format.")
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	cases := map[string]string{"a.json": "json", "b.YML": "yaml", "c.yaml": "yaml", "d.csv": "csv", "e.md": "md"}
+	for file, expected := range cases {
+		if format, err := formatFromPath(file); err != nil || format != expected {
+			t.Errorf("Wrong format for %s, got %s %v", file, format, err)
+		}
+	}
+	if _, err := formatFromPath("commands.txt"); err == nil {
+		t.Error("No error for unknown extension.")
+	}
+}
+
+func TestDecodeHandWrittenYAML(t *testing.T) {
+	data := `# curated commands
+---
+- cmd: kubectl get pods -A   # all namespaces
+  tags:
+    - pods
+    - 'k8s'
+- cmd: |
+    psql \
+      -h localhost
+  description: 'it''s the db'
+  tags: []
+`
+	lines, err := decodeYAML([]byte(data))
+	if err != nil {
+		t.Fatalf("Error decoding YAML, got %s", err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("Wrong number of lines, got %d", len(lines))
+	}
+	if lines[0].cmd != "kubectl get pods -A" || lines[0].tagList() != "pods,k8s" {
+		t.Errorf("Wrong first line, got %+v", lines[0])
+	}
+	if lines[1].cmd != "psql \\\n  -h localhost" || lines[1].description != "it's the db" {
+		t.Errorf("Wrong second line, got %+v", lines[1])
+	}
+	for _, invalid := range []string{"cmd: ls\n", "- cmd ls\n", "- foo: bar\n", "- created: yesterday\n"} {
+		if _, err := decodeYAML([]byte(invalid)); err == nil {
+			t.Errorf("No error for %s", invalid)
+		}
+	}
+}
+
+func TestDecodeCSVColumns(t *testing.T) {
+	lines, err := decodeCSV([]byte("tags,cmd\n\"db,backup\",pg_dump prod\n,ls\n"))
+	if err != nil {
+		t.Fatalf("Error decoding CSV, got %s", err)
+	}
+	if len(lines) != 2 || lines[0].tagList() != "db,backup" || lines[1].cmd != "ls" {
+		t.Errorf("Wrong lines, got %+v", lines)
+	}
+	if _, err := decodeCSV([]byte("id,tags\nabc,foo\n")); err == nil {
+		t.Error("No error without cmd column.")
+	}
+}
+
+func TestImportFile(t *testing.T) {
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	file := path.Join(t.TempDir(), "commands.csv")
+	ioutil.WriteFile(file, []byte("id,tags,cmd\nkft8sg,,echo test\nkft8sg,foo,make\n123456,,pwd\n"), 0644)
+
+	rescueStdout := os.Stdout
+	os.Stdout, _ = os.Open(os.DevNull)
+	defer func() { os.Stdout = rescueStdout }()
+
+	// tag foo is used by line 1
+	if err := rem.importFile(file, "csv", importOptions{}); err == nil {
+		t.Error("No error for used tag.")
+	}
+	rem.read()
+	if len(rem.lines) != 3 {
+		t.Fatalf("Lines imported despite error, got %d", len(rem.lines))
+	}
+
+	if err := rem.importFile(file, "csv", importOptions{skipDuplicates: true, overwriteTags: true}); err != nil {
+		t.Fatalf("Error importing, got %s", err)
+	}
+	rem.read()
+	if len(rem.lines) != 5 {
+		t.Fatalf("Wrong number of lines, got %d", len(rem.lines))
+	}
+	if rem.lines[1].tagList() != "" || rem.lines[3].tagList() != "foo" || rem.lines[3].cmd != "make" {
+		t.Errorf("Tag not moved, got %s %s", rem.lines[1].tagList(), rem.lines[3].tagList())
+	}
+	// ids in use or invalid are replaced
+	if rem.lines[3].id == "kft8sg" || rem.lines[4].id == "123456" || !validID(rem.lines[4].id) {
+		t.Errorf("Wrong ids, got %s %s", rem.lines[3].id, rem.lines[4].id)
+	}
+	if rem.lines[3].created.IsZero() || rem.lines[3].author == "" {
+		t.Errorf("Timestamps or author missing, got %+v", rem.lines[3])
+	}
+}
+
+func TestImportRenumber(t *testing.T) {
+	rem := getTestEmptyRem(t)
+	defer removeRemFile(rem)
+	data, _ := exportLines(getExchangeLines(), "json")
+	file := path.Join(t.TempDir(), "commands.json")
+	ioutil.WriteFile(file, data, 0644)
+
+	rescueStdout := os.Stdout
+	os.Stdout, _ = os.Open(os.DevNull)
+	err := rem.importFile(file, "json", importOptions{renumber: true})
+	os.Stdout = rescueStdout
+	if err != nil {
+		t.Fatalf("Error importing, got %s", err)
+	}
+
+	rem.read()
+	for i, l := range getExchangeLines() {
+		if rem.lines[i].id == l.id || rem.lines[i].cmd != l.cmd {
+			t.Errorf("Line %d not renumbered, got %s", i, rem.lines[i].id)
+		}
+	}
+}
+
+func TestExportMarkdown(t *testing.T) {
+	data := string(encodeMarkdown(getExchangeLines()[1:2]))
+	if !strings.Contains(data, "## count\n\n> counts 'foo, bar'\n\n```sh\ngrep") || !strings.Contains(data, "- tags: `count`, `grep`\n") {
+		t.Errorf("Wrong markdown, got %s", data)
+	}
+}
+
+func TestImportMarkdownPlainDescription(t *testing.T) {
+	lines, err := decodeMarkdown([]byte("# Commands\n\n## list\n\nlists files\nin the dir\n\n```sh\nls\n```\n"))
+	if err != nil || len(lines) != 1 || lines[0].cmd != "ls" || lines[0].description != "lists files\nin the dir" {
+		t.Errorf("Plain description not read, got %+v %v", lines, err)
+	}
+}
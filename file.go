@@ -17,27 +17,45 @@
 package main
 
 import (
-	_ "fmt"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"os/user"
 	"path"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
 )
 
 type File struct {
+	path     string // used as filepath without looking up filename
 	filepath string
 	filename string
 	file     *os.File
+	lockFile *os.File
 	global   bool
-}
-
-func (f *File) clearFile() error {
-	return os.Remove(f.filepath)
+	checksum string
+	changed  bool
 }
 
 func (f *File) Close() {
 	f.file.Close()
 }
 
+func (f *File) readAll() ([]byte, error) {
+	// Reads the opened rem file and remembers a checksum of its content,
+	// changed is set when the content differs from the previous read.
+	data, err := ioutil.ReadAll(f.file)
+	if err != nil {
+		return nil, err
+	}
+	sum := fmt.Sprintf("%x", sha256.Sum256(data))
+	f.changed = f.checksum != "" && f.checksum != sum
+	f.checksum = sum
+	return data, nil
+}
+
 func (f *File) setFile(appendTo bool) error {
 	// which mode to use to open file
 	var openFlags int
@@ -49,9 +67,10 @@ func (f *File) setFile(appendTo bool) error {
 
 	// open history file
 	file, err := os.OpenFile(f.filepath, openFlags, 0600)
-	if err == nil {
-		f.file = file
+	if err != nil {
+		return err
 	}
+	f.file = file
 	return nil
 }
 
@@ -82,6 +101,12 @@ func (f *File) checkPath(dir string) bool {
 }
 
 func (f *File) setPath() error {
+	// fixed path, e.g. for layers
+	if f.path != "" {
+		f.filepath = f.path
+		return nil
+	}
+
 	// ignore current dir if global .rem file is wanted
 	if f.global == false {
 		// Set path to history file in current dir if one exists
@@ -104,16 +129,135 @@ func (f *File) setPath() error {
 	}
 
 	// Set default path to rem's history file
-	usr, err := user.Current()
+	globalPath, err := f.globalPath()
 	if err == nil {
-		f.filepath = path.Join(usr.HomeDir, f.filename)
+		f.filepath = globalPath
 	}
 	return err
 }
 
-func (f *File) write(line string) error {
-	if _, err := f.file.WriteString(line); err != nil {
-		panic(err)
+func (f *File) globalPath() (string, error) {
+	// Returns the path of the global rem file in the home dir,
+	// unless another one is configured.
+	if config.GlobalFile != "" {
+		return expandHome(config.GlobalFile), nil
+	}
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(usr.HomeDir, f.filename), nil
+}
+
+func (f *File) cascadePaths() ([]string, error) {
+	// Returns all existing rem files from the current dir up to the root,
+	// nearest first, followed by the global rem file.
+	paths := []string{}
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if f.checkPath(dir) {
+			paths = append(paths, path.Join(dir, f.filename))
+		}
+		if dir == "/" {
+			break
+		}
+		dir = path.Dir(dir)
+	}
+
+	globalPath, err := f.globalPath()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range paths {
+		if p == globalPath {
+			return paths, nil
+		}
+	}
+	if _, err := os.Stat(globalPath); err == nil {
+		paths = append(paths, globalPath)
+	}
+	return paths, nil
+}
+
+func (f *File) lock() error {
+	// Locks the rem file exclusively, blocks until other rem processes
+	// released their lock.
+	for {
+		file, err := os.OpenFile(f.filepath, os.O_CREATE|os.O_RDONLY, 0600)
+		if err != nil {
+			return err
+		}
+		if err := unix.Flock(int(file.Fd()), unix.LOCK_EX); err != nil {
+			file.Close()
+			return err
+		}
+
+		// the file might have been replaced while waiting for the lock
+		locked, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return err
+		}
+		current, err := os.Stat(f.filepath)
+		if err == nil && os.SameFile(locked, current) {
+			f.lockFile = file
+			return nil
+		}
+		file.Close()
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+}
+
+func (f *File) unlock() {
+	// Releases the lock taken with lock().
+	if f.lockFile != nil {
+		f.lockFile.Close()
+		f.lockFile = nil
+	}
+}
+
+func (f *File) writeFile(data []byte) error {
+	// Writes data to a temporary file which then replaces the rem file,
+	// readers see either the old or the new content, never a partial one.
+	target := f.filepath
+	if resolved, err := filepath.EvalSymlinks(target); err == nil {
+		target = resolved
+	}
+
+	// keep permissions of an existing file
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(target); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := ioutil.TempFile(path.Dir(target), "."+path.Base(target)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return err
 	}
+	f.checksum = fmt.Sprintf("%x", sha256.Sum256(data))
 	return nil
 }
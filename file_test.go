@@ -18,11 +18,13 @@ package main
 
 import (
 	_ "fmt"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
 	"testing"
+	"time"
 )
 
 func removeTestFile(f *os.File) {
@@ -200,3 +202,87 @@ func TestCheckPath(t *testing.T) {
 	}
 	defer removeTestFile(file.file)
 }
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	file := &File{
+		filepath: path.Join(dir, ".rem_test_write"),
+	}
+	if err := ioutil.WriteFile(file.filepath, []byte("old\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	link := &File{
+		filepath: path.Join(dir, ".rem_test_link"),
+	}
+	if err := os.Symlink(file.filepath, link.filepath); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := link.writeFile([]byte("new\n")); err != nil {
+		t.Errorf("Error writing file, got %s", err)
+	}
+
+	content, _ := ioutil.ReadFile(file.filepath)
+	if string(content) != "new\n" {
+		t.Errorf("Wrong content written, got %s", content)
+	}
+	info, _ := os.Lstat(link.filepath)
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Error("Symlink was replaced.")
+	}
+	info, _ = os.Stat(file.filepath)
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("Permissions not kept, got %s", info.Mode())
+	}
+
+	// no temporary files left
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 2 {
+		t.Errorf("Temporary files left, got %d files", len(files))
+	}
+}
+
+func TestLock(t *testing.T) {
+	file := &File{
+		filepath: path.Join(t.TempDir(), ".rem_test_lock"),
+	}
+	if err := file.lock(); err != nil {
+		t.Fatalf("Error locking file, got %s", err)
+	}
+
+	// a second lock has to wait until the first one is released
+	locked := make(chan bool)
+	go func() {
+		other := &File{filepath: file.filepath}
+		if err := other.lock(); err != nil {
+			t.Errorf("Error locking file, got %s", err)
+		}
+		other.unlock()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		t.Error("File was locked twice.")
+	case <-time.After(100 * time.Millisecond):
+	}
+	file.unlock()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Error("Lock was not released.")
+	}
+}
+
+func TestCascadePaths(t *testing.T) {
+	_, dir := getLayeredRem(t)
+
+	file := &File{filename: testLayerFile}
+	paths, err := file.cascadePaths()
+	if err != nil {
+		t.Fatalf("Error when looking up paths, got %s", err)
+	}
+	if len(paths) != 2 || paths[0] != path.Join(dir, "sub", testLayerFile) || paths[1] != path.Join(dir, testLayerFile) {
+		t.Errorf("Wrong paths, got %v", paths)
+	}
+}
@@ -0,0 +1,165 @@
+// rem - A tool to remember things on the command line.
+// Copyright (C) 2015 Martin Borho (martin@borho.net)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Version of the rem file format written by this release.
+const formatVersion = 3
+
+// First record of a versioned rem file, files without it are
+// read in the legacy "#tag#cmd" line format.
+type header struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+}
+
+// One entry of a rem file as stored on disk.
+type record struct {
+	ID          string     `json:"id,omitempty"`
+	Tag         string     `json:"tag,omitempty"` // version 1, single tag
+	Tags        []string   `json:"tags,omitempty"`
+	Cmd         string     `json:"cmd"`
+	Description string     `json:"description,omitempty"`
+	Created     *time.Time `json:"created,omitempty"`
+	Modified    *time.Time `json:"modified,omitempty"`
+	Author      string     `json:"author,omitempty"`
+	History     []revision `json:"history,omitempty"`
+	Discovered  bool       `json:"discovered,omitempty"` // added by discover
+}
+
+func toTimePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func fromTimePtr(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+func (l *Line) toRecord() *record {
+	return &record{
+		ID:          l.id,
+		Tags:        l.tags,
+		Cmd:         l.cmd,
+		Description: l.description,
+		Created:     toTimePtr(l.created),
+		Modified:    toTimePtr(l.modified),
+		Author:      l.author,
+		History:     l.history,
+		Discovered:  l.discovered,
+	}
+}
+
+func (l *Line) fromRecord(rec *record) {
+	l.id = rec.ID
+	l.tags = rec.Tags
+	if rec.Tag != "" {
+		l.tags = append([]string{rec.Tag}, l.tags...)
+	}
+	l.cmd = rec.Cmd
+	l.description = rec.Description
+	l.created = fromTimePtr(rec.Created)
+	l.modified = fromTimePtr(rec.Modified)
+	l.author = rec.Author
+	l.history = rec.History
+	l.discovered = rec.Discovered
+}
+
+// Checks if data starts with the header of a versioned rem file.
+func isVersioned(data []byte) bool {
+	first := bytes.TrimSpace(bytes.SplitN(data, []byte("\n"), 2)[0])
+	h := header{}
+	if err := json.Unmarshal(first, &h); err != nil {
+		return false
+	}
+	return h.Format == "rem"
+}
+
+// Parses the content of a rem file, returns the lines and
+// whether the legacy format was found.
+func decodeLines(data []byte) ([]*Line, bool, error) {
+	lines := []*Line{}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return lines, false, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	// legacy format, one command per line
+	if !isVersioned(data) {
+		for scanner.Scan() {
+			l := &Line{}
+			l.read(scanner.Text())
+			lines = append(lines, l)
+		}
+		return lines, true, scanner.Err()
+	}
+
+	// versioned format, header followed by one JSON record per line
+	scanner.Scan()
+	h := header{}
+	if err := json.Unmarshal(scanner.Bytes(), &h); err != nil {
+		return nil, false, err
+	}
+	if h.Version > formatVersion {
+		return nil, false, fmt.Errorf("Rem file format version %d is not supported, please upgrade rem.", h.Version)
+	}
+	for n := 2; scanner.Scan(); n++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		rec := &record{}
+		if err := json.Unmarshal(scanner.Bytes(), rec); err != nil {
+			return nil, false, fmt.Errorf("Invalid entry in line %d: %s", n, err)
+		}
+		l := &Line{}
+		l.fromRecord(rec)
+		lines = append(lines, l)
+	}
+	return lines, false, scanner.Err()
+}
+
+// Serializes lines into the current rem file format.
+func encodeLines(lines []*Line) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	// keep operators like && readable in the file
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(&header{Format: "rem", Version: formatVersion}); err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		if err := enc.Encode(line.toRecord()); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeLegacyLines(t *testing.T) {
+	lines, legacy, err := decodeLines([]byte("ls\n#foo#ls -la\n#bar#echo #baz#\n"))
+	if err != nil {
+		t.Fatalf("Error decoding legacy lines, got %s", err)
+	}
+	if !legacy {
+		t.Error("Legacy format not detected.")
+	}
+	if len(lines) != 3 {
+		t.Fatalf("Wrong number of lines, got %d", len(lines))
+	}
+	if lines[1].tagList() != "foo" || lines[1].cmd != "ls -la" {
+		t.Errorf("Wrong line decoded, got %s %s", lines[1].tagList(), lines[1].cmd)
+	}
+	if lines[2].tagList() != "bar" || lines[2].cmd != "echo #baz#" {
+		t.Errorf("Wrong line decoded, got %s %s", lines[2].tagList(), lines[2].cmd)
+	}
+}
+
+func TestDecodeEmpty(t *testing.T) {
+	lines, legacy, err := decodeLines([]byte(""))
+	if err != nil || legacy || len(lines) != 0 {
+		t.Errorf("Empty file not decoded correctly, got %v %v %v", lines, legacy, err)
+	}
+}
+
+func TestEncodeDecodeLines(t *testing.T) {
+	created := time.Date(2023, 4, 1, 12, 0, 0, 0, time.UTC)
+	lines := []*Line{
+		{cmd: "ls"},
+		{cmd: "#foo#bar && baz <x>", tags: []string{"foo"}, description: "a tag-like command", created: created, modified: created,
+			history: []revision{{Cmd: "bar", Modified: created}}},
+	}
+	data, err := encodeLines(lines)
+	if err != nil {
+		t.Fatalf("Error encoding lines, got %s", err)
+	}
+	if !strings.HasPrefix(string(data), fmt.Sprintf(`{"format":"rem","version":%d}`+"\n", formatVersion)) {
+		t.Errorf("Header not written, got %s", data)
+	}
+	if !strings.Contains(string(data), "bar && baz <x>") {
+		t.Errorf("Command was escaped, got %s", data)
+	}
+
+	decoded, legacy, err := decodeLines(data)
+	if err != nil {
+		t.Fatalf("Error decoding lines, got %s", err)
+	}
+	if legacy {
+		t.Error("Versioned file detected as legacy.")
+	}
+	if len(decoded) != 2 {
+		t.Fatalf("Wrong number of lines, got %d", len(decoded))
+	}
+	if decoded[0].cmd != "ls" || decoded[0].tagList() != "" || !decoded[0].created.IsZero() {
+		t.Errorf("Wrong first line, got %+v", decoded[0])
+	}
+	l := decoded[1]
+	if l.cmd != "#foo#bar && baz <x>" || l.tagList() != "foo" || l.description != "a tag-like command" {
+		t.Errorf("Wrong second line, got %+v", l)
+	}
+	if !l.created.Equal(created) || !l.modified.Equal(created) {
+		t.Errorf("Wrong timestamps, got %s %s", l.created, l.modified)
+	}
+	if len(l.history) != 1 || l.history[0].Cmd != "bar" || !l.history[0].Modified.Equal(created) {
+		t.Errorf("Wrong history, got %+v", l.history)
+	}
+}
+
+func TestDecodeVersion1(t *testing.T) {
+	lines, _, err := decodeLines([]byte(`{"format":"rem","version":1}` + "\n" + `{"id":"abc234","tag":"foo","cmd":"ls"}` + "\n"))
+	if err != nil {
+		t.Fatalf("Error decoding lines, got %s", err)
+	}
+	if lines[0].tagList() != "foo" || lines[0].id != "abc234" {
+		t.Errorf("Single tag not decoded, got %+v", lines[0])
+	}
+}
+
+func TestDecodeUnsupportedVersion(t *testing.T) {
+	_, _, err := decodeLines([]byte(`{"format":"rem","version":99}` + "\n"))
+	if err == nil {
+		t.Error("No error for unsupported format version.")
+	}
+}
+
+func TestDecodeInvalidEntry(t *testing.T) {
+	_, _, err := decodeLines([]byte(`{"format":"rem","version":1}` + "\n{broken\n"))
+	if err == nil {
+		t.Error("No error for invalid entry.")
+	}
+}
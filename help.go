@@ -30,27 +30,126 @@ USAGE:
 COMMANDS:
     -h, help - Shows this help.
     -a, add [string] - Adds a command/text.
-    rm [index] - Removes line with given index number.
-    echo [index] - Displays line with given index number.
-    edit [index] - Opens default editor in $EDITOR for editing a command.
+    rm [index|id|tag] - Removes line with given index number, id or tag.
+    echo [index|id|tag] [name=value] - Displays line with given index number, id or tag,
+        with its placeholders filled in.
+    edit [index|id|tag] - Opens default editor in $EDITOR for editing a command.
+    info [index|id|tag] - Shows description, author and timestamps of a command.
+    tag [index|id|tag] [tags] - Sets the comma separated tags of a command.
+    untag [index|id|tag] - Removes all tags of a command.
+    history [index|id|tag] - Shows all versions of a command with their changes.
+    revert [index|id|tag] [rev] - Sets a command back to a version from its history.
     -f, filter [regexp] - Filters stored commands by given regular expression.
     here - Creates a .rem file in the given directory. Default: ~/.rem
     clear - Clears currently active .rem file, ./.rem or ~/.rem
-    [index|tag] - Executes line with given index number / tag name.
+    undo - Reverts the last rm, edit, tag, untag or clear.
+    trash - Lists removed commands.
+    trash restore [index] - Adds a removed command from the trash again.
+    import-history - Shows recent commands of the shell history to pick from.
+        --shell [bash|zsh|fish] - Shell to read the history of. Default: $SHELL
+        --file [path] - History file. Default: $HISTFILE or the shell's default
+        --limit [n] - Number of recent commands to show. Default: 20
+    discover - Offers the targets of Makefile, package.json scripts and justfile
+               recipes as commands tagged make:x, npm:x or just:x, keeps them in sync.
+               The task files are read from the directory of the local rem file.
+        --all - Add all new tasks without asking.
+    export-shell - Writes every tagged command as shell function, named by tag.
+                   Commands with placeholders and tags which are shell
+                   keywords or builtins are skipped.
+        --shell [bash|zsh|fish] - Shell to write functions for. Default: $SHELL
+        --abbr - Write fish abbreviations instead of functions.
+    log - Lists the commands run in child mode with exit status and duration.
+        --tag [tag|id] - Only runs of commands with this tag or id.
+        --since [time] - Only runs since e.g. 30m, 12h, 1d, 2w or 2006-01-02.
+        --failed - Only runs with an exit status other than 0.
+    stats - Shows run count, last run and average duration of every command.
+    export - Writes all commands to stdout.
+        --format [json|yaml|csv|md] - Format to export in. Default: json
+        --output [file] - File to write to instead of stdout.
+    import [file] - Adds the commands of an exported file, - for stdin.
+        --format [json|yaml|csv|md] - Format of the file. Default: by extension
+        --skip-duplicates - Skip commands which exist already.
+        --overwrite-tags - Take used tags away from existing commands.
+        --renumber - Give all imported commands new ids.
+    notebooks - Lists all notebooks with their number of commands.
+    cp [index|id|tag] [notebook] - Copies a command to a notebook, "global" or "local".
+    mv [index|id|tag] [notebook] - Moves a command to a notebook, "global" or "local".
+    [index|id|tag] [name=value] [-- args] - Executes line with given index number / id / tag name.
+        Arguments after -- are appended quoted, or used as $1, $@ if the command has them
+        outside of single quotes.
+
+    Commands can have placeholders like {{ns}} or {{host:=localhost}} with
+    a default. Values are taken from name=value arguments, environment
+    variables or the default, missing ones are asked for. Values are
+    quoted, they never run as shell code. Commands with other {{ }}
+    templates, like Go templates, are left as they are, write \{{ for
+    literal braces next to placeholders.
 
     Run 'rem' without arguments to list all stored commands/strings.
+    Prefix id or tag with "global:", "shared:" or a dir like "../:" to
+    address the line in another rem file.
+
+    Read-only commands shared by a team are read from /etc/rem.d/*.rem
+    and the dirs or files in $REM_SHARED_PATH, separated by ":".
 
 FLAGS:
     -g - Use global rem file ~/.rem
-    -t - Tag for command when adding with -a/add.
+    -n - Use the named notebook in $XDG_DATA_HOME/rem/notebooks.
+    -c - Cascade, also list the rem files of all parent dirs and ~/.rem.
+    -t - Comma separated tags for command when adding with -a/add.
+         Without a command only lines with one of the tags are listed.
+    -d - Description for command when adding with -a/add.
     -p - Print command to stdout before executing index/tag.
+    -m - Read a multi-line command/script from stdin until EOF when adding.
+    --last - Add the previous command from the shell history, also "add --last".
+    -r - Add the arguments as they are, without quoting them.
+    --force - Use an index number even if the line changed since the last listing.
+    --replace - Replace the line using the same tag when adding, move tags with tag.
+    --child - Run the command as child process, rem exits with its exit status.
+    --time - Print exit status and wall time after running the command.
+    --sort [order] - Sort listing, filter output and stats by frecency, recent,
+                     count or alpha.
+    -y - Don't ask for confirmation when clearing.
+
+CONFIG:
+    Settings are read from $XDG_CONFIG_HOME/rem/config, default
+    ~/.config/rem/config, as "key = value" lines. REM_ followed by the
+    upper case key overrides a setting, e.g. REM_EDITOR=vim.
+
+    global_file - Path of the global rem file. Default: ~/.rem
+    filename - Name of local rem files. Default: .rem
+    editor - Editor for edit. Default: $EDITOR or nano
+    shell - Shell executing commands. Default: the calling shell
+    color - Colored listings, auto, always or never. Default: auto
+    confirm - Ask before clearing, true or false. Default: true
+    list_format - Format of listed lines with {index}, {id}, {tags},
+                  {cmd}, {description} and {author}.
+    shared_path - Dirs or files with shared commands. Default: /etc/rem.d
+    exec_mode - exec replaces rem with the command, child runs it as child
+                process. Default: exec
+    timing - Print exit status and wall time of commands. Default: false
 
 EXAMPLES:
     rem add ls -la - Adds "ls -la" to list.
     rem -t list add ls -la - Adds "ls -la" to list with tag "list".
+    rem add grep "foo bar" file - Adds "grep 'foo bar' file", quotes are kept.
+    rem -d "list all files" add ls -la - Adds "ls -la" with a description.
+    rem -t db,backup add pg_dump prod - Adds "pg_dump prod" with tags "db" and "backup".
+    rem -t db - Lists all lines tagged with "db".
     rem list - Executes line tagged with "list" (ls-la)
     rem 2 - Executes line with index number 2.
+    rem test -- -run TestFoo -v - Executes line tagged "test" with the arguments appended.
+    rem deploy ns=staging - Executes line tagged "deploy" with {{ns}} set to staging.
+    rem k3mxqa - Executes line with id "k3mxqa".
+    rem -m add < script.sh - Adds the content of script.sh as one entry.
     rem rm 4 - Removes line 4.
+    rem -n k8s add kubectl get pods - Adds a command to notebook "k8s".
+    rem mv 3 sql - Moves line 3 to notebook "sql".
+    rem export --format md --output COMMANDS.md - Documents all commands.
+    rem import --skip-duplicates team.yaml - Adds missing commands of a curated set.
+    eval "$(rem export-shell)" - Defines a shell function for every tagged command.
+    rem -c - Lists the commands of all rem files up to ~/.rem.
+    rem global:deploy - Executes line tagged with "deploy" in ~/.rem.
     rem - Lists all stored commands.
     `
 }
@@ -0,0 +1,279 @@
+// rem - A tool to remember things on the command line.
+// Copyright (C) 2015 Martin Borho (martin@borho.net)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A command from the history of a shell.
+type historyEntry struct {
+	cmd  string
+	time time.Time
+}
+
+// Returns the history file of the shell, $HISTFILE if it is set.
+func historyFile(shell string) (string, error) {
+	if file := os.Getenv("HISTFILE"); file != "" && shell == path.Base(os.Getenv("SHELL")) {
+		return file, nil
+	}
+	switch shell {
+	case "bash":
+		return expandHome("~/.bash_history"), nil
+	case "zsh":
+		if dir := os.Getenv("ZDOTDIR"); dir != "" {
+			return path.Join(dir, ".zsh_history"), nil
+		}
+		return expandHome("~/.zsh_history"), nil
+	case "fish":
+		dir := os.Getenv("XDG_DATA_HOME")
+		if dir == "" {
+			dir = expandHome("~/.local/share")
+		}
+		return path.Join(dir, "fish", "fish_history"), nil
+	}
+	return "", fmt.Errorf("Unsupported shell %s, use bash, zsh or fish.", shell)
+}
+
+// Reads the history of bash, zsh or fish, oldest entry first.
+func readHistory(shell, file string) ([]historyEntry, error) {
+	if file == "" {
+		var err error
+		if file, err = historyFile(shell); err != nil {
+			return nil, err
+		}
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	switch shell {
+	case "bash":
+		return parseBashHistory(data), nil
+	case "zsh":
+		return parseZshHistory(data), nil
+	case "fish":
+		return parseFishHistory(data), nil
+	}
+	return nil, fmt.Errorf("Unsupported shell %s, use bash, zsh or fish.", shell)
+}
+
+// Parses bash history, with HISTTIMEFORMAT set commands are preceded
+// by a "#<unix time>" line.
+func parseBashHistory(data []byte) []historyEntry {
+	entries := []historyEntry{}
+	var when time.Time
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			if ts, err := strconv.ParseInt(line[1:], 10, 64); err == nil {
+				when = time.Unix(ts, 0)
+				continue
+			}
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		entries = append(entries, historyEntry{cmd: line, time: when})
+		when = time.Time{}
+	}
+	return entries
+}
+
+// Parses zsh history, plain or in the extended format
+// ": <unix time>:<duration>;<command>". Lines of multi-line commands
+// end with a backslash.
+func parseZshHistory(data []byte) []historyEntry {
+	entries := []historyEntry{}
+	lines := strings.Split(unmetafy(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		for strings.HasSuffix(line, "\\") && i+1 < len(lines) {
+			i++
+			line = strings.TrimSuffix(line, "\\") + "\n" + lines[i]
+		}
+		entry := historyEntry{cmd: line}
+		if strings.HasPrefix(line, ": ") {
+			if semi := strings.Index(line, ";"); semi > 0 {
+				meta := strings.SplitN(line[2:semi], ":", 2)
+				if ts, err := strconv.ParseInt(meta[0], 10, 64); err == nil {
+					entry = historyEntry{cmd: line[semi+1:], time: time.Unix(ts, 0)}
+				}
+			}
+		}
+		if strings.TrimSpace(entry.cmd) != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Reverts the metafication zsh uses for special bytes in its history.
+func unmetafy(data []byte) string {
+	const meta = 0x83
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] == meta && i+1 < len(data) {
+			i++
+			out = append(out, data[i]^32)
+			continue
+		}
+		out = append(out, data[i])
+	}
+	return string(out)
+}
+
+// Parses the fish history, entries look like "- cmd: <command>" followed
+// by "  when: <unix time>" and escape newlines and backslashes.
+func parseFishHistory(data []byte) []historyEntry {
+	entries := []historyEntry{}
+	unescape := strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+	for _, line := range strings.Split(string(data), "\n") {
+		switch {
+		case strings.HasPrefix(line, "- cmd: "):
+			entries = append(entries, historyEntry{cmd: unescape.Replace(line[len("- cmd: "):])})
+		case strings.HasPrefix(line, "  when: ") && len(entries) > 0:
+			if ts, err := strconv.ParseInt(strings.TrimSpace(line[len("  when: "):]), 10, 64); err == nil {
+				entries[len(entries)-1].time = time.Unix(ts, 0)
+			}
+		}
+	}
+	return entries
+}
+
+// Returns whether the command is a call of rem itself.
+func isRemCall(cmd string) bool {
+	fields := strings.Fields(cmd)
+	return len(fields) > 0 && path.Base(fields[0]) == "rem"
+}
+
+// Returns up to limit of the most recent commands, newest first,
+// without duplicates and calls of rem.
+func recentCommands(entries []historyEntry, limit int) []historyEntry {
+	recent := []historyEntry{}
+	seen := map[string]bool{}
+	for i := len(entries) - 1; i >= 0 && len(recent) < limit; i-- {
+		cmd := strings.TrimSpace(entries[i].cmd)
+		if seen[cmd] || isRemCall(cmd) {
+			continue
+		}
+		seen[cmd] = true
+		recent = append(recent, historyEntry{cmd: cmd, time: entries[i].time})
+	}
+	return recent
+}
+
+// A command chosen in the picker with its tags.
+type pick struct {
+	index int
+	tags  string
+}
+
+// Parses a selection like "1 3-5 7=deploy,k8s" of numbers from 1 to
+// count, tags can be given for single numbers.
+func parseSelection(input string, count int) ([]pick, error) {
+	picks := []pick{}
+	for _, token := range strings.Fields(input) {
+		tags := ""
+		if i := strings.Index(token, "="); i >= 0 {
+			token, tags = token[:i], token[i+1:]
+		}
+		from, to := token, token
+		if i := strings.Index(token, "-"); i > 0 {
+			if tags != "" {
+				return nil, errors.New("Tags can only be given for single commands.")
+			}
+			from, to = token[:i], token[i+1:]
+		}
+		first, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid selection %s.", token)
+		}
+		last, err := strconv.Atoi(to)
+		if err != nil || first < 1 || last > count || first > last {
+			return nil, fmt.Errorf("Invalid selection %s, choose from 1 to %d.", token, count)
+		}
+		for n := first; n <= last; n++ {
+			picks = append(picks, pick{index: n - 1, tags: tags})
+		}
+	}
+	return picks, nil
+}
+
+func (r *Rem) importHistory(shell, file string, limit int) error {
+	// Shows the recent commands of the shell history numbered and adds
+	// the chosen ones.
+	entries, err := readHistory(shell, file)
+	if err != nil {
+		return err
+	}
+	recent := recentCommands(entries, limit)
+	if len(recent) == 0 {
+		return errors.New("No commands found in history.")
+	}
+	w := r.getTabWriter()
+	for n, entry := range recent {
+		fmt.Fprintf(w, " %d\t%s\n", n+1, strings.Split(entry.cmd, "\n")[0])
+	}
+	w.Flush()
+
+	fmt.Print("Add which commands? (e.g. 1 3-5 7=tag, empty to cancel) ")
+	input, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	picks, err := parseSelection(strings.TrimSpace(input), len(recent))
+	if err != nil {
+		return err
+	}
+	for _, p := range picks {
+		if err := r.appendLine(recent[p.index].cmd, p.tags); err != nil {
+			return err
+		}
+		fmt.Println(recent[p.index].cmd)
+	}
+	return nil
+}
+
+// Returns the name of the login shell from $SHELL, bash if not set.
+func currentShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return path.Base(shell)
+	}
+	return "bash"
+}
+
+// Returns the previous command from the history of the current shell.
+func lastCommand() (string, error) {
+	entries, err := readHistory(currentShell(), "")
+	if err != nil {
+		return "", err
+	}
+	recent := recentCommands(entries, 1)
+	if len(recent) == 0 {
+		return "", errors.New("No previous command found in history.")
+	}
+	return recent[0].cmd, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestParseBashHistory(t *testing.T) {
+	entries := parseBashHistory([]byte("ls -la\n#1700000000\ngit status\n\nmake test\n"))
+	if len(entries) != 3 {
+		t.Fatalf("Wrong number of entries, got %d", len(entries))
+	}
+	if entries[1].cmd != "git status" || entries[1].time.Unix() != 1700000000 {
+		t.Errorf("Wrong timestamped entry, got %+v", entries[1])
+	}
+	if entries[2].cmd != "make test" || !entries[2].time.IsZero() {
+		t.Errorf("Wrong entry, got %+v", entries[2])
+	}
+}
+
+func TestParseZshHistory(t *testing.T) {
+	data := ": 1700000000:0;ls -la\n: 1700000010:3;for f in *; do\\\n  echo $f\\\ndone\nplain command\n: 1700000020:0;echo caf\x83\xa9\n"
+	entries := parseZshHistory([]byte(data))
+	if len(entries) != 4 {
+		t.Fatalf("Wrong number of entries, got %d", len(entries))
+	}
+	if entries[0].cmd != "ls -la" || entries[0].time.Unix() != 1700000000 {
+		t.Errorf("Wrong first entry, got %+v", entries[0])
+	}
+	if entries[1].cmd != "for f in *; do\n  echo $f\ndone" {
+		t.Errorf("Wrong multi-line entry, got %q", entries[1].cmd)
+	}
+	if entries[2].cmd != "plain command" || !entries[2].time.IsZero() {
+		t.Errorf("Wrong plain entry, got %+v", entries[2])
+	}
+	if entries[3].cmd != "echo caf\x89" {
+		t.Errorf("Metafied entry not decoded, got %q", entries[3].cmd)
+	}
+}
+
+func TestParseFishHistory(t *testing.T) {
+	data := "- cmd: ls -la\n  when: 1700000000\n- cmd: echo a\\nb \\\\n\n  when: 1700000010\n  paths:\n    - a\n"
+	entries := parseFishHistory([]byte(data))
+	if len(entries) != 2 {
+		t.Fatalf("Wrong number of entries, got %d", len(entries))
+	}
+	if entries[0].cmd != "ls -la" || entries[0].time.Unix() != 1700000000 {
+		t.Errorf("Wrong first entry, got %+v", entries[0])
+	}
+	if entries[1].cmd != "echo a\nb \\n" {
+		t.Errorf("Escapes not replaced, got %q", entries[1].cmd)
+	}
+}
+
+func TestRecentCommands(t *testing.T) {
+	entries := parseBashHistory([]byte("ls\nmake\nrem add ls\nls\n/usr/bin/rem 2\ngit status\n"))
+	recent := recentCommands(entries, 10)
+	if len(recent) != 3 || recent[0].cmd != "git status" || recent[1].cmd != "ls" || recent[2].cmd != "make" {
+		t.Errorf("Wrong recent commands, got %+v", recent)
+	}
+	if recent := recentCommands(entries, 1); len(recent) != 1 {
+		t.Errorf("Limit ignored, got %d", len(recent))
+	}
+}
+
+func TestParseSelection(t *testing.T) {
+	picks, err := parseSelection("1 3-4 6=deploy,k8s", 6)
+	if err != nil {
+		t.Fatalf("Error parsing selection, got %s", err)
+	}
+	expected := []pick{{0, ""}, {2, ""}, {3, ""}, {5, "deploy,k8s"}}
+	if len(picks) != len(expected) {
+		t.Fatalf("Wrong picks, got %+v", picks)
+	}
+	for i := range expected {
+		if picks[i] != expected[i] {
+			t.Errorf("Wrong pick %d, got %+v", i, picks[i])
+		}
+	}
+	for _, input := range []string{"0", "7", "a", "3-1", "1-2=foo"} {
+		if _, err := parseSelection(input, 6); err == nil {
+			t.Errorf("No error for %s", input)
+		}
+	}
+}
+
+func TestImportHistory(t *testing.T) {
+	rem := getTestEmptyRem(t)
+	defer removeRemFile(rem)
+	file := path.Join(t.TempDir(), "history")
+	ioutil.WriteFile(file, []byte("ls -la\ngit status\nmake test\n"), 0600)
+
+	funcDefer, err := mockStdin(t, "1 3=ls\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer funcDefer()
+	rescueStdout := os.Stdout
+	os.Stdout, _ = os.Open(os.DevNull)
+	err = rem.importHistory("bash", file, 20)
+	os.Stdout = rescueStdout
+
+	if err != nil {
+		t.Fatalf("Error importing history, got %s", err)
+	}
+	rem.read()
+	if len(rem.lines) != 2 || rem.lines[0].cmd != "make test" || rem.lines[1].cmd != "ls -la" || rem.lines[1].tagList() != "ls" {
+		t.Errorf("Wrong lines imported, got %d", len(rem.lines))
+	}
+}
+
+func TestLastCommand(t *testing.T) {
+	t.Setenv("SHELL", "/bin/bash")
+	t.Setenv("HISTFILE", path.Join(t.TempDir(), "history"))
+	ioutil.WriteFile(os.Getenv("HISTFILE"), []byte("make test\nrem add --last\n"), 0600)
+
+	cmd, err := lastCommand()
+	if err != nil || cmd != "make test" {
+		t.Errorf("Wrong last command, got %s %v", cmd, err)
+	}
+}
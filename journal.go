@@ -0,0 +1,259 @@
+// rem - A tool to remember things on the command line.
+// Copyright (C) 2015 Martin Borho (martin@borho.net)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Number of changes kept in the journal.
+const journalSize = 100
+
+// A line as it was before a change.
+type journalLine struct {
+	Index int     `json:"index"`
+	Line  *record `json:"line"`
+}
+
+// A destructive change of the rem file, with the lines before the change.
+type change struct {
+	Time  time.Time     `json:"time"`
+	Op    string        `json:"op"`
+	Lines []journalLine `json:"lines"`
+}
+
+// Returns the path of the journal next to the rem file.
+func (f *File) journalPath() string {
+	return f.filepath + ".journal"
+}
+
+func (r *Rem) remember(op string, index int) {
+	// Keeps the line at index as it is before it gets changed, the
+	// change gets journaled when the rem file was written.
+	if r.pending == nil {
+		r.pending = &change{Time: time.Now(), Op: op}
+	}
+	r.pending.Lines = append(r.pending.Lines, journalLine{
+		Index: index,
+		Line:  r.lines[index].toRecord(),
+	})
+}
+
+func (r *Rem) readJournal() ([]*change, error) {
+	// Reads all changes from the journal, oldest first.
+	changes := []*change{}
+	data, err := ioutil.ReadFile(r.journalPath())
+	if os.IsNotExist(err) {
+		return changes, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		c := &change{}
+		if err := json.Unmarshal(line, c); err != nil {
+			return nil, fmt.Errorf("Invalid journal %s: %s", r.journalPath(), err)
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+func (r *Rem) writeJournal(changes []*change) error {
+	// Writes the most recent changes to the journal.
+	if len(changes) > journalSize {
+		changes = changes[len(changes)-journalSize:]
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	for _, c := range changes {
+		if err := enc.Encode(c); err != nil {
+			return err
+		}
+	}
+	journal := &File{filepath: r.journalPath()}
+	return journal.writeFile(buf.Bytes())
+}
+
+func (r *Rem) journalPending() error {
+	// Adds the pending change to the journal.
+	if r.pending == nil {
+		return nil
+	}
+	changes, err := r.readJournal()
+	if err != nil {
+		return err
+	}
+	err = r.writeJournal(append(changes, r.pending))
+	r.pending = nil
+	return err
+}
+
+func (r *Rem) clear() error {
+	// Removes all lines, they are kept in the journal. The file itself
+	// stays, so undo still finds it.
+	return r.update(func() error {
+		for i := range r.lines {
+			r.remember("clear", i)
+		}
+		r.lines = []*Line{}
+		return nil
+	})
+}
+
+func (r *Rem) undo() error {
+	// Reverts the last journaled change.
+	if err := r.setPath(); err != nil {
+		return err
+	}
+	if err := r.lock(); err != nil {
+		return err
+	}
+	defer r.unlock()
+
+	if err := r.read(); err != nil {
+		return err
+	}
+	changes, err := r.readJournal()
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return errors.New("Nothing to undo.")
+	}
+	last := changes[len(changes)-1]
+
+	// restore lines in the order of their index
+	sort.SliceStable(last.Lines, func(i, j int) bool {
+		return last.Lines[i].Index < last.Lines[j].Index
+	})
+	dropped := []string{}
+	for _, jl := range last.Lines {
+		l := &Line{}
+		l.fromRecord(jl.Line)
+		// tags might be used by another line meanwhile
+		for _, tag := range l.tags {
+			for _, other := range r.lines {
+				if other.id != l.id && other.hasTag(tag) {
+					l.removeTag(tag)
+					dropped = append(dropped, tag)
+					break
+				}
+			}
+		}
+		if index, err := r.getIndexByID(l.id); err == nil {
+			// changed line
+			r.lines[index] = l
+			continue
+		}
+		// removed line
+		index := jl.Index
+		if index > len(r.lines) {
+			index = len(r.lines)
+		}
+		r.lines = append(r.lines[:index], append([]*Line{l}, r.lines[index:]...)...)
+	}
+	if err := r.save(); err != nil {
+		return err
+	}
+	fmt.Printf("Reverted %s from %s.\n", last.Op, last.Time.Local().Format("2006-01-02 15:04:05"))
+	if len(dropped) > 0 {
+		fmt.Printf("Dropped tags used by other lines: %s\n", strings.Join(dropped, ", "))
+	}
+	return r.writeJournal(changes[:len(changes)-1])
+}
+
+// A line from the trash with the time it was removed.
+type removedLine struct {
+	line    *Line
+	removed time.Time
+}
+
+func (r *Rem) trash() ([]*removedLine, error) {
+	// Returns removed lines which weren't restored yet, latest first.
+	changes, err := r.readJournal()
+	if err != nil {
+		return nil, err
+	}
+	lines := []*removedLine{}
+	seen := map[string]bool{}
+	for i := len(changes) - 1; i >= 0; i-- {
+		if changes[i].Op != "rm" && changes[i].Op != "clear" && changes[i].Op != "sync" {
+			continue
+		}
+		for _, jl := range changes[i].Lines {
+			l := &Line{}
+			l.fromRecord(jl.Line)
+			if _, err := r.getIndexByID(l.id); err == nil || seen[l.id] {
+				continue
+			}
+			seen[l.id] = true
+			lines = append(lines, &removedLine{line: l, removed: changes[i].Time})
+		}
+	}
+	return lines, nil
+}
+
+func (r *Rem) printTrash() error {
+	// Print removed lines enumerated, with time of removal
+	lines, err := r.trash()
+	if err != nil {
+		return err
+	}
+	w := r.getTabWriter()
+	for x, rl := range lines {
+		fmt.Fprintf(w, " %d\t%s\t%s\t%s\n", x, rl.removed.Local().Format("2006-01-02 15:04"), rl.line.id, rl.line.summary())
+	}
+	return w.Flush()
+}
+
+func (r *Rem) restore(index int) error {
+	// Adds a removed line from the trash back to the rem file.
+	lines, err := r.trash()
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= len(lines) {
+		return errors.New("Index out of range.")
+	}
+	restored := lines[index].line
+	return r.update(func() error {
+		if _, err := r.getIndexByID(restored.id); err == nil {
+			restored.id = r.newID()
+		}
+		// tags might be used by another line meanwhile
+		for _, tag := range restored.tags {
+			if _, err := r.getIndexByTag(tag); err != errTagNotFound {
+				restored.removeTag(tag)
+			}
+		}
+		restored.modified = time.Now()
+		r.lines = append(r.lines, restored)
+		return nil
+	})
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestUndoRemove(t *testing.T) {
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	if err := rem.removeLine(1); err != nil {
+		t.Fatalf("Error when removing line, got %s", err)
+	}
+	if err := rem.appendLine("pwd", ""); err != nil {
+		t.Fatalf("Error when appending line, got %s", err)
+	}
+
+	rescueStdout := os.Stdout
+	os.Stdout, _ = os.Open(os.DevNull)
+	err := rem.undo()
+	os.Stdout = rescueStdout
+	if err != nil {
+		t.Fatalf("Error when undoing, got %s", err)
+	}
+
+	// removed line is back at its index, later changes are kept
+	rem.read()
+	cmds := []string{}
+	for _, line := range rem.lines {
+		cmds = append(cmds, line.cmd)
+	}
+	if strings.Join(cmds, "|") != "ls|ls -la|echo test|pwd" {
+		t.Errorf("Wrong lines after undo, got %v", cmds)
+	}
+	if rem.lines[1].id != "rwz6fj" || rem.lines[1].tagList() != "foo" {
+		t.Errorf("Line not restored, got %+v", rem.lines[1])
+	}
+
+	if err := rem.undo(); err == nil {
+		t.Error("No error when nothing is left to undo.")
+	}
+}
+
+func TestUndoRemoveTagTaken(t *testing.T) {
+	rem := getRem(t, "#foo#echo one\n")
+	defer removeRemFile(rem)
+	rem.read()
+
+	if err := rem.removeLine(0); err != nil {
+		t.Fatalf("Error when removing line, got %s", err)
+	}
+	if err := rem.addLine(&Line{cmd: "echo two", tags: []string{"foo"}}); err != nil {
+		t.Fatalf("Error when adding line, got %s", err)
+	}
+
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	err := rem.undo()
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+	os.Stdout = rescueStdout
+	if err != nil {
+		t.Fatalf("Error when undoing, got %s", err)
+	}
+
+	// the tag stays with the line which has it now
+	rem.read()
+	if index, err := rem.getIndexByTag("foo"); err != nil || rem.lines[index].cmd != "echo two" {
+		t.Errorf("Tag not unique after undo, got %d %v", index, err)
+	}
+	if len(rem.lines) != 2 || rem.lines[0].cmd != "echo one" || len(rem.lines[0].tags) != 0 {
+		t.Errorf("Line not restored without tag, got %+v", rem.lines[0])
+	}
+	if !strings.Contains(string(out), "Dropped tags used by other lines: foo") {
+		t.Errorf("Dropped tag not reported, got %s", out)
+	}
+}
+
+func TestUndoEditTag(t *testing.T) {
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	if err := rem.replaceLine(1, "ls -lah"); err != nil {
+		t.Fatalf("Error when replacing line, got %s", err)
+	}
+	rem.replace = true
+	if err := rem.retag(0, []string{"foo"}); err != nil {
+		t.Fatalf("Error when moving tag, got %s", err)
+	}
+
+	rescueStdout := os.Stdout
+	os.Stdout, _ = os.Open(os.DevNull)
+	rem.undo()
+	rem.read()
+	if rem.lines[0].tagList() != "" || rem.lines[1].tagList() != "foo" || rem.lines[1].cmd != "ls -lah" {
+		t.Errorf("Tag change not reverted, got %+v %+v", rem.lines[0], rem.lines[1])
+	}
+	rem.undo()
+	os.Stdout = rescueStdout
+
+	rem.read()
+	if rem.lines[1].cmd != "ls -la" {
+		t.Errorf("Edit not reverted, got %s", rem.lines[1].cmd)
+	}
+}
+
+func TestClearTrashRestore(t *testing.T) {
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	if err := rem.removeLine(2); err != nil {
+		t.Fatalf("Error when removing line, got %s", err)
+	}
+	if err := rem.clear(); err != nil {
+		t.Fatalf("Error when clearing, got %s", err)
+	}
+	if rem.read(); len(rem.lines) != 0 {
+		t.Errorf("Rem file not cleared, got %d lines", len(rem.lines))
+	}
+
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	rem.read()
+	rem.printTrash()
+
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+	os.Stdout = rescueStdout
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[2], "kft8sg  echo test") {
+		t.Fatalf("Wrong trash, got %s", out)
+	}
+
+	// restore the removed line with tag
+	if err := rem.restore(1); err != nil {
+		t.Fatalf("Error when restoring, got %s", err)
+	}
+	rem.read()
+	if len(rem.lines) != 1 || rem.lines[0].cmd != "ls -la" || rem.lines[0].tagList() != "foo" {
+		t.Errorf("Line not restored, got %d lines", len(rem.lines))
+	}
+	if trash, _ := rem.trash(); len(trash) != 2 {
+		t.Errorf("Restored line still in trash, got %d lines", len(trash))
+	}
+	if err := rem.restore(5); err == nil {
+		t.Error("No error for index out of range.")
+	}
+}
@@ -0,0 +1,177 @@
+// rem - A tool to remember things on the command line.
+// Copyright (C) 2015 Martin Borho (martin@borho.net)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Splits a reference like "global:deploy" or "../:3" into the layer
+// qualifier and the reference within the layer.
+func parseQualifier(ref string) (string, string) {
+	i := strings.Index(ref, ":")
+	if i < 0 {
+		return "", ref
+	}
+	qualifier := ref[:i]
+	if qualifier == "global" || qualifier == "shared" || strings.HasSuffix(qualifier, "/") {
+		return qualifier, ref[i+1:]
+	}
+	return "", ref
+}
+
+func (r *Rem) newLayer(filepath string) *Rem {
+	// Returns a rem for another file with the same settings.
+	return &Rem{
+		File: File{
+			path:     filepath,
+			filename: r.filename,
+		},
+		printBeforeExec: r.printBeforeExec,
+		child:           r.child,
+		timing:          r.timing,
+		force:           r.force,
+		replace:         r.replace,
+	}
+}
+
+func (r *Rem) readLayers() error {
+	// Reads the rem files of all parent directories and the global
+	// rem file as additional layers.
+	r.layers = []*Rem{}
+	paths, err := r.cascadePaths()
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		if p == r.filepath {
+			continue
+		}
+		layer := r.newLayer(p)
+		if err := layer.read(); err != nil {
+			return fmt.Errorf("%s: %s", p, err)
+		}
+		r.layers = append(r.layers, layer)
+	}
+	return nil
+}
+
+func (r *Rem) allLayers() []*Rem {
+	// Returns the rem itself followed by its layers and the shared one.
+	layers := append([]*Rem{r}, r.layers...)
+	if r.shared != nil {
+		layers = append(layers, r.shared)
+	}
+	return layers
+}
+
+func (r *Rem) countLines() int {
+	// Returns the number of lines in all layers.
+	count := 0
+	for _, layer := range r.allLayers() {
+		count += len(layer.lines)
+	}
+	return count
+}
+
+func (r *Rem) label() string {
+	// Returns the qualifier addressing this rem file, "shared", "global"
+	// or its directory relative to the current one.
+	if r.readonly {
+		return "shared"
+	}
+	if path.Dir(r.filepath) == notebookDir() {
+		return strings.TrimSuffix(path.Base(r.filepath), ".rem")
+	}
+	if globalPath, err := r.globalPath(); err == nil && globalPath == r.filepath {
+		return "global"
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return path.Dir(r.filepath) + "/"
+	}
+	rel, err := filepath.Rel(cwd, path.Dir(r.filepath))
+	if err != nil {
+		return path.Dir(r.filepath) + "/"
+	}
+	return rel + "/"
+}
+
+func (r *Rem) printHeader(w io.Writer) {
+	// Prints qualifier and path of the layer before its lines.
+	fmt.Fprintf(w, "[%s] %s\n", r.label(), r.filepath)
+}
+
+func (r *Rem) findLayer(qualifier string) (*Rem, error) {
+	// Returns the layer addressed by qualifier, layers get read if
+	// not in cascade mode.
+	if r.layers == nil {
+		if err := r.readLayers(); err != nil {
+			return nil, err
+		}
+	}
+	dir := filepath.Clean(qualifier)
+	if !filepath.IsAbs(dir) {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(cwd, dir)
+	}
+	for _, layer := range r.allLayers() {
+		label := layer.label()
+		if (qualifier == "global" || qualifier == "shared") && label == qualifier {
+			return layer, nil
+		}
+		if !layer.readonly && path.Dir(layer.filepath) == dir {
+			return layer, nil
+		}
+	}
+	return nil, fmt.Errorf("No rem file found for %s.", qualifier)
+}
+
+func (r *Rem) locate(ref string) (*Rem, int, error) {
+	// Returns the layer and index of a line addressed by an optionally
+	// qualified reference. Ids and tags are looked up nearest first,
+	// index numbers without qualifier address the rem file itself.
+	qualifier, ref := parseQualifier(ref)
+	if qualifier != "" {
+		layer, err := r.findLayer(qualifier)
+		if err != nil {
+			return nil, 0, err
+		}
+		index, err := layer.resolve(ref)
+		return layer, index, err
+	}
+
+	index, err := r.resolve(ref)
+	if err != errNotFound {
+		return r, index, err
+	}
+	for _, layer := range r.allLayers()[1:] {
+		index, err := layer.resolve(ref)
+		if err != errNotFound {
+			return layer, index, err
+		}
+	}
+	return nil, 0, err
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+)
+
+const testLayerFile = ".rem_layer_test"
+
+func getLayeredRem(t *testing.T) (*Rem, string) {
+	// Creates rem files in a temp dir and a sub dir of it and changes
+	// into the sub dir, returns the rem and the temp dir.
+	t.Setenv("TMPDIR", t.TempDir())
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := path.Join(dir, "sub")
+	os.Mkdir(sub, 0755)
+	files := map[string]string{
+		dir: "#build#make all\n#deploy#./deploy.sh\n",
+		sub: "#build#go build ./...\necho sub\n",
+	}
+	for d, content := range files {
+		if err := ioutil.WriteFile(path.Join(d, testLayerFile), []byte(content), 0644); err != nil {
+			t.Fatalf("Cannot create rem testfile, %s", err)
+		}
+	}
+
+	cwd, _ := os.Getwd()
+	if err := os.Chdir(sub); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(cwd) })
+
+	rem := &Rem{File: File{filename: testLayerFile}}
+	rem.read()
+	if err := rem.readLayers(); err != nil {
+		t.Fatalf("Error reading layers, got %s", err)
+	}
+	return rem, dir
+}
+
+func TestParseQualifier(t *testing.T) {
+	cases := map[string][2]string{
+		"deploy":        {"", "deploy"},
+		"global:deploy": {"global", "deploy"},
+		"../:build":     {"../", "build"},
+		"/srv/app/:3":   {"/srv/app/", "3"},
+		"foo:bar":       {"", "foo:bar"},
+	}
+	for ref, expected := range cases {
+		qualifier, rest := parseQualifier(ref)
+		if qualifier != expected[0] || rest != expected[1] {
+			t.Errorf("Wrong qualifier for %s, got %s %s", ref, qualifier, rest)
+		}
+	}
+}
+
+func TestReadLayers(t *testing.T) {
+	rem, dir := getLayeredRem(t)
+
+	if len(rem.layers) != 1 || rem.layers[0].filepath != path.Join(dir, testLayerFile) {
+		t.Fatalf("Wrong layers, got %v", rem.layers)
+	}
+	if rem.countLines() != 4 {
+		t.Errorf("Wrong number of lines, got %d", rem.countLines())
+	}
+	if rem.label() != "./" || rem.layers[0].label() != "../" {
+		t.Errorf("Wrong labels, got %s %s", rem.label(), rem.layers[0].label())
+	}
+}
+
+func TestLocate(t *testing.T) {
+	rem, _ := getLayeredRem(t)
+	parent := rem.layers[0]
+
+	cases := []struct {
+		ref   string
+		layer *Rem
+		index int
+	}{
+		{"build", rem, 0},
+		{"deploy", parent, 1},
+		{"1", rem, 1},
+		{"../:build", parent, 0},
+		{"../:0", parent, 0},
+		{"./:build", rem, 0},
+	}
+	for _, c := range cases {
+		layer, index, err := rem.locate(c.ref)
+		if err != nil || layer != c.layer || index != c.index {
+			t.Errorf("Wrong line for %s, got %v %d %v", c.ref, layer, index, err)
+		}
+	}
+	for _, ref := range []string{"missing", "2", "../:echo", "../../:build", "global:build"} {
+		if _, _, err := rem.locate(ref); err == nil {
+			t.Errorf("No error for %s", ref)
+		}
+	}
+}
+
+func TestLocateWithoutCascade(t *testing.T) {
+	getLayeredRem(t)
+	rem := &Rem{File: File{filename: testLayerFile}}
+	rem.read()
+
+	if _, _, err := rem.locate("deploy"); err == nil {
+		t.Error("Parent dir used without cascade.")
+	}
+	layer, index, err := rem.locate("../:deploy")
+	if err != nil || layer == rem || index != 1 {
+		t.Errorf("Qualified ref not found, got %d %v", index, err)
+	}
+}
+
+func TestPrintAllLinesCascade(t *testing.T) {
+	rem, dir := getLayeredRem(t)
+
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	rem.printAllLines()
+
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+	os.Stdout = rescueStdout
+
+	expected := "[./] " + path.Join(dir, "sub", testLayerFile) + "\n" +
+		" 0  e99ccc  build  go build ./...\n" +
+		" 1  cjct4g   -     echo sub\n" +
+		"[../] " + path.Join(dir, testLayerFile) + "\n" +
+		" 0  s4m2pn  build   make all\n" +
+		" 1  xk8xcd  deploy  ./deploy.sh\n"
+	if string(out) != expected {
+		t.Errorf("Wrong line output, got %s", out)
+	}
+}
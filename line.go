@@ -17,32 +17,127 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/sha1"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path"
 	"regexp"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/shirou/gopsutil/v3/process"
 	"golang.org/x/sys/unix"
 )
 
+// Characters used for entry IDs, without easily confused ones.
+const idChars = "abcdefghijkmnpqrstuvwxyz23456789"
+
+// Number of letters at the start of idChars, IDs start with one of
+// them and can't be mistaken for an index.
+const idLetters = 24
+
+// A previous version of a command.
+type revision struct {
+	Cmd      string    `json:"cmd"`
+	Modified time.Time `json:"modified"`
+}
+
 type Line struct {
-	line     string
-	cmd      string
-	tag      string
-	execFlag string
+	id          string
+	cmd         string
+	tags        []string
+	description string
+	created     time.Time
+	modified    time.Time
+	author      string
+	history     []revision
+	discovered  bool
+	source      string
+	execFlag    string
+}
+
+// Builds an ID out of the given bytes.
+func idFromBytes(b []byte) string {
+	id := []byte{idChars[int(b[0])%idLetters]}
+	for _, c := range b[1:6] {
+		id = append(id, idChars[int(c)%len(idChars)])
+	}
+	return string(id)
+}
+
+// Returns a new random ID for an entry.
+func newID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return idFromBytes(b)
+}
+
+// Sets an ID derived from position and content for entries without one,
+// it stays the same until the entry gets written with it.
+func (l *Line) deriveID(index int) {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%d\x00%s\x00%s", index, l.tagList(), l.cmd)))
+	l.id = idFromBytes(sum[:])
+}
+
+// Splits a comma separated list of tags.
+func parseTags(str string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(str, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Checks if the line is tagged with tag.
+func (l *Line) hasTag(tag string) bool {
+	for _, t := range l.tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Removes tag from the line.
+func (l *Line) removeTag(tag string) {
+	kept := []string{}
+	for _, t := range l.tags {
+		if t != tag {
+			kept = append(kept, t)
+		}
+	}
+	l.tags = kept
+}
+
+// Checks if str can be used as a tag, numbers would be taken as index.
+func checkTag(tag string) error {
+	if _, err := toInt(tag); err == nil {
+		return fmt.Errorf("Tag %s is a number, it can't be used as a tag.", tag)
+	}
+	return nil
 }
 
-// Read incoming string into Line struct.
+// Returns the tags of the line comma separated.
+func (l *Line) tagList() string {
+	return strings.Join(l.tags, ",")
+}
+
+// Read incoming string in the legacy "#tag#cmd" format into Line struct.
 func (l *Line) read(line string) {
 	re := regexp.MustCompile("^#([^ ]+)?#")
-	l.line = line
 	if tagMatch := re.FindSubmatch([]byte(line)); tagMatch != nil {
-		l.tag = string(tagMatch[1])
-		l.cmd = line[len(l.tag)+2:]
+		l.tags = parseTags(string(tagMatch[1]))
+		l.cmd = line[len(tagMatch[1])+2:]
 	} else {
 		// no tag found, simple command
 		l.cmd = line
@@ -51,7 +146,10 @@ func (l *Line) read(line string) {
 
 // Edit opens the line in a text editor and returns the edited string.
 func (l *Line) edit() (string, error) {
-	editor := os.Getenv("EDITOR")
+	editor := config.Editor
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
 	if editor == "" {
 		editor = "nano" // Fallback to 'nano' as the default editor if $EDITOR is not set
 	}
@@ -82,52 +180,282 @@ func (l *Line) edit() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return strings.ReplaceAll(strings.ReplaceAll(strings.TrimSpace(string(modifiedText)), "\r\n", " "), "\n", " "), nil
+	// keep newlines of multi-line commands
+	return strings.TrimSpace(strings.ReplaceAll(string(modifiedText), "\r\n", "\n")), nil
 }
 
-func (l *Line) execute(printCmd bool) error {
-	// get the pid of the calling shell
-	p, err := process.NewProcess(int32(os.Getppid()))
+func (l *Line) execute(printCmd bool, args []string) error {
+	callerPath, err := shellPath()
 	if err != nil {
 		return err
 	}
 
-	// path of calling shell
-	callerPath, err := p.Exe()
+	// print cmd before executing
+	if printCmd == true {
+		fmt.Println(l.withArgs(args))
+	}
+
+	// replace the current process
+	err = unix.Exec(callerPath, l.execArgs(callerPath, args), os.Environ())
 	if err != nil {
 		return err
 	}
+	return nil
+}
 
+// Checks if rem is in the foreground process group of its terminal,
+// which gets the signals of Ctrl-C and Ctrl-\ as a whole.
+var inForeground = func() bool {
+	pgrp, err := unix.IoctlGetInt(int(os.Stdin.Fd()), unix.TIOCGPGRP)
+	return err == nil && pgrp == syscall.Getpgrp()
+}
+
+// Runs the command as child process of rem with the same stdio and
+// returns its exit status, signals to rem are forwarded. SIGINT and
+// SIGQUIT of the terminal reach the child already if rem runs in the
+// foreground, they aren't forwarded then. With timing the exit status
+// and wall time get printed afterwards.
+func (l *Line) run(printCmd bool, args []string, timing bool) (int, error) {
+	callerPath, err := shellPath()
+	if err != nil {
+		return 0, err
+	}
+	if printCmd == true {
+		fmt.Println(l.withArgs(args))
+	}
+
+	execParts := l.execArgs(callerPath, args)
+	cmd := exec.Command(execParts[0], execParts[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	defer signal.Stop(signals)
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if (sig != syscall.SIGINT && sig != syscall.SIGQUIT) || !inForeground() {
+					cmd.Process.Signal(sig)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	cmd.Wait()
+	code := exitCode(cmd.ProcessState)
+	if timing {
+		fmt.Fprintf(os.Stderr, "rem: exit %d after %s\n", code, time.Since(start).Round(time.Millisecond))
+	}
+	return code, nil
+}
+
+// Returns the exit status of a process, 128 plus the signal number
+// like shells do if it was killed by a signal.
+func exitCode(state *os.ProcessState) int {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return state.ExitCode()
+}
+
+// Returns the arguments to run the command with the shell, extra
+// arguments are positional parameters: /bin/bash -c "ls -la $1" rem /tmp
+func (l *Line) execArgs(shell string, args []string) []string {
 	// define 'execute' flag if not set
 	if l.execFlag == "" {
 		l.execFlag = "-c"
 	}
+	execParts := []string{shell, l.execFlag, l.withArgs(args)}
+	if len(args) > 0 {
+		// fish has no $0, its $argv starts with the first argument
+		if path.Base(shell) != "fish" {
+			execParts = append(execParts, "rem")
+		}
+		execParts = append(execParts, args...)
+	}
+	return execParts
+}
 
-	// print cmd before executing
-	if printCmd == true {
-		fmt.Println(l.cmd)
+// Matches a reference to positional parameters like $@, $1 or $argv.
+var positionalRef = regexp.MustCompile(`^\$(@|\*|[1-9]|\{[1-9@*]|argv)`)
+
+// Scans the command for positional parameters outside of single quotes,
+// $1 in awk '{print $1}' belongs to awk, and for a trailing comment.
+func (l *Line) scanArgs() (positional bool, comment bool) {
+	singleQuoted, doubleQuoted := false, false
+	for i := 0; i < len(l.cmd); i++ {
+		switch c := l.cmd[i]; {
+		case singleQuoted:
+			singleQuoted = c != '\''
+		case c == '\\':
+			i++
+		case c == '\'' && !doubleQuoted:
+			singleQuoted = true
+		case c == '"':
+			doubleQuoted = !doubleQuoted
+		case c == '$' && positionalRef.MatchString(l.cmd[i:]):
+			positional = true
+		case c == '#' && !doubleQuoted && (i == 0 || strings.ContainsRune(" \t\n;&|(", rune(l.cmd[i-1]))):
+			// the rest of the line is a comment
+			for i < len(l.cmd) && l.cmd[i] != '\n' {
+				i++
+			}
+			comment = true
+		}
 	}
+	return positional, comment
+}
 
-	// /bin/bash -c "ls -la"
-	execParts := []string{callerPath, l.execFlag, l.cmd}
+// Checks if the command uses positional parameters like $1 or $@.
+func (l *Line) usesArgs() bool {
+	positional, _ := l.scanArgs()
+	return positional
+}
 
-	// replace the current process
-	err = unix.Exec(callerPath, execParts, os.Environ())
+// Checks if arguments can be appended to the command, it has to be a
+// single line ending in a word, not in a comment, ; or &.
+func (l *Line) appendsArgs() bool {
+	positional, comment := l.scanArgs()
+	trimmed := strings.TrimRight(l.cmd, " \t")
+	return !positional && !comment && !strings.Contains(l.cmd, "\n") &&
+		!strings.HasSuffix(trimmed, ";") && !strings.HasSuffix(trimmed, "&")
+}
+
+// Returns the command with the extra arguments appended quoted, unless
+// it uses them as positional parameters or they can't be appended.
+func (l *Line) withArgs(args []string) string {
+	if len(args) == 0 || !l.appendsArgs() {
+		return l.cmd
+	}
+	return l.cmd + " " + shellJoin(args)
+}
+
+// Warns if the arguments get lost, commands with several lines or
+// ending in a comment, ; or & only get them as positional parameters.
+func (l *Line) warnUnusedArgs(args []string) {
+	if len(args) > 0 && !l.usesArgs() && !l.appendsArgs() {
+		fmt.Fprintln(os.Stderr, "rem: arguments not used, they can't be appended and the command has no $1 or \"$@\".")
+	}
+}
+
+// Returns the configured shell or the one rem was called from.
+func shellPath() (string, error) {
+	if config.Shell != "" {
+		return config.Shell, nil
+	}
+	// get the pid of the calling shell
+	p, err := process.NewProcess(int32(os.Getppid()))
 	if err != nil {
-		return err
+		return "", err
 	}
-	return nil
+	// path of calling shell
+	return p.Exe()
+}
+
+// Returns the first line of the command, multi-line commands
+// get marked with the number of following lines.
+func (l *Line) summary() string {
+	lines := strings.Split(l.cmd, "\n")
+	if len(lines) == 1 {
+		return l.cmd
+	}
+	return fmt.Sprintf("%s (+%d lines)", lines[0], len(lines)-1)
 }
 
 // Prints line to tabwriter.
 func (l *Line) print(w io.Writer, index int, withTag bool) {
+	if config.ListFormat != "" {
+		fmt.Fprintln(w, strings.NewReplacer(
+			"{index}", fmt.Sprint(index),
+			"{id}", l.id,
+			"{tags}", l.tagList(),
+			"{cmd}", l.summary(),
+			"{description}", l.description,
+			"{author}", l.author,
+		).Replace(config.ListFormat))
+		return
+	}
+	id := paint(colorID, l.id)
 	if withTag {
 		tag := ""
-		if tag = l.tag; tag == "" {
+		if tag = l.tagList(); tag == "" {
 			tag = " - "
 		}
-		fmt.Fprintf(w, " %d\t%s\t%s\n", index, tag, l.cmd)
+		fmt.Fprintf(w, " %d\t%s\t%s\t%s\n", index, id, paint(colorTag, tag), l.summary())
 	} else {
-		fmt.Fprintf(w, " %d\t%s\n", index, l.cmd)
+		fmt.Fprintf(w, " %d\t%s\t%s\n", index, id, l.summary())
+	}
+}
+
+// Prints all fields of the line to tabwriter.
+func (l *Line) printInfo(w io.Writer, index int) {
+	orNone := func(str string) string {
+		if str == "" {
+			return "-"
+		}
+		return str
+	}
+	timeOrNone := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Local().Format("2006-01-02 15:04:05")
+	}
+	fmt.Fprintf(w, "index:\t%d\n", index)
+	fmt.Fprintf(w, "id:\t%s\n", l.id)
+	fmt.Fprintf(w, "tags:\t%s\n", orNone(l.tagList()))
+	fmt.Fprintf(w, "description:\t%s\n", orNone(l.description))
+	fmt.Fprintf(w, "author:\t%s\n", orNone(l.author))
+	fmt.Fprintf(w, "created:\t%s\n", timeOrNone(l.created))
+	fmt.Fprintf(w, "modified:\t%s\n", timeOrNone(l.modified))
+	if l.source != "" {
+		fmt.Fprintf(w, "source:\t%s (read-only)\n", l.source)
+	}
+	fmt.Fprintf(w, "command:\t%s\n", strings.ReplaceAll(l.cmd, "\n", "\n\t"))
+}
+
+// Returns all versions of the command, the current one last.
+func (l *Line) revisions() []revision {
+	return append(append([]revision{}, l.history...), revision{Cmd: l.cmd, Modified: l.modified})
+}
+
+// Prints all versions of the command with the changes between them.
+func (l *Line) printHistory(w io.Writer) {
+	revs := l.revisions()
+	for x, rev := range revs {
+		modified := "-"
+		if !rev.Modified.IsZero() {
+			modified = rev.Modified.Local().Format("2006-01-02 15:04:05")
+		}
+		current := ""
+		if x == len(revs)-1 {
+			current = " (current)"
+		}
+		fmt.Fprintf(w, "rev %d  %s%s\n", x, modified, current)
+
+		diff := []string{}
+		if x == 0 {
+			for _, line := range strings.Split(rev.Cmd, "\n") {
+				diff = append(diff, "  "+line)
+			}
+		} else {
+			diff = diffLines(revs[x-1].Cmd, rev.Cmd)
+		}
+		fmt.Fprintf(w, "%s\n", strings.Join(diff, "\n"))
+		if x < len(revs)-1 {
+			fmt.Fprintln(w)
+		}
 	}
 }
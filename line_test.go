@@ -2,8 +2,14 @@ package main
 
 import (
 	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestParseLine(t *testing.T) {
@@ -11,7 +17,7 @@ func TestParseLine(t *testing.T) {
 	l := &Line{}
 	l.read(line)
 
-	if l.tag != "foo" {
+	if l.tagList() != "foo" {
 		t.Errorf("Can't detect tag for %s", line)
 	}
 
@@ -22,7 +28,7 @@ func TestParseLine(t *testing.T) {
 	l = &Line{}
 	line = "ls -la"
 	l.read(line)
-	if l.tag != "" {
+	if l.tagList() != "" {
 		t.Errorf("Tag was found in %s", line)
 	}
 
@@ -32,6 +38,20 @@ func TestParseLine(t *testing.T) {
 
 }
 
+func TestParseTags(t *testing.T) {
+	l := &Line{}
+	l.read("#db,backup#pg_dump prod")
+	if l.tagList() != "db,backup" || l.cmd != "pg_dump prod" {
+		t.Errorf("Tags not parsed, got %s %s", l.tagList(), l.cmd)
+	}
+	if !l.hasTag("backup") || l.hasTag("prod") {
+		t.Errorf("Wrong tags, got %s", l.tagList())
+	}
+	if tags := parseTags(" db, ,prod "); strings.Join(tags, "|") != "db|prod" {
+		t.Errorf("Tags not parsed, got %v", tags)
+	}
+}
+
 /*func TestExecute(t *testing.T) {
 	l := &Line{
 		cmd: "/not/abdcdef",
@@ -47,33 +67,334 @@ func TestParseLine(t *testing.T) {
 func TestPrint(t *testing.T) {
 	// test without tag
 	l := &Line{
+		id:  "abc234",
 		cmd: "echo foobar",
 	}
 	var b bytes.Buffer
 	l.print(&b, 2, false)
 
-	if strings.Compare(b.String(), " 2\techo foobar\n") != 0 {
+	if strings.Compare(b.String(), " 2\tabc234\techo foobar\n") != 0 {
 		t.Error("Line was printed incorrect.")
 	}
 
 	// test with tag but without line tag
 	l = &Line{
+		id:  "def567",
 		cmd: "foo",
 	}
 	var d bytes.Buffer
 	l.print(&d, 3, true)
-	if strings.Compare(d.String(), " 3\t - \tfoo\n") != 0 {
+	if strings.Compare(d.String(), " 3\tdef567\t - \tfoo\n") != 0 {
 		t.Error("Line was printed incorrect.")
 	}
 
 	// test with tag
 	l = &Line{
-		tag: "foo",
-		cmd: "bar",
+		id:   "ghk892",
+		tags: []string{"foo"},
+		cmd:  "bar",
 	}
 	var c bytes.Buffer
 	l.print(&c, 4, true)
-	if strings.Compare(c.String(), " 4\tfoo\tbar\n") != 0 {
+	if strings.Compare(c.String(), " 4\tghk892\tfoo\tbar\n") != 0 {
 		t.Error("Line with tag was printed incorrect.")
 	}
 }
+
+func TestPrintMultiLine(t *testing.T) {
+	l := &Line{
+		id:  "abc234",
+		cmd: "for i in 1 2 3; do\n  echo $i\ndone",
+	}
+	if l.summary() != "for i in 1 2 3; do (+2 lines)" {
+		t.Errorf("Wrong summary, got %s", l.summary())
+	}
+
+	var b bytes.Buffer
+	l.print(&b, 1, false)
+	if b.String() != " 1\tabc234\tfor i in 1 2 3; do (+2 lines)\n" {
+		t.Errorf("Multi-line command was printed incorrect, got %s", b.String())
+	}
+}
+
+func TestIDs(t *testing.T) {
+	id := newID()
+	if len(id) != 6 || strings.Trim(id, idChars) != "" {
+		t.Errorf("Invalid id, got %s", id)
+	}
+	if _, err := toInt(id); err == nil {
+		t.Errorf("ID can be mistaken for an index, got %s", id)
+	}
+
+	// derived ids are stable
+	l := &Line{cmd: "ls -la", tags: []string{"foo"}}
+	l.deriveID(1)
+	other := &Line{cmd: "ls -la", tags: []string{"foo"}}
+	other.deriveID(1)
+	if l.id != other.id {
+		t.Errorf("Derived ids differ, got %s and %s", l.id, other.id)
+	}
+	other.deriveID(2)
+	if l.id == other.id {
+		t.Errorf("Same id derived for different positions, got %s", l.id)
+	}
+}
+
+func TestPrintInfo(t *testing.T) {
+	created := time.Date(2023, 4, 1, 12, 0, 0, 0, time.Local)
+	l := &Line{
+		id:          "abc234",
+		tags:        []string{"restore"},
+		cmd:         "pg_restore \\\n  dump.sql",
+		description: "restore prod db dump",
+		author:      "martin",
+		created:     created,
+	}
+	var b bytes.Buffer
+	l.printInfo(&b, 3)
+
+	expected := "index:\t3\nid:\tabc234\ntags:\trestore\ndescription:\trestore prod db dump\n" +
+		"author:\tmartin\ncreated:\t2023-04-01 12:00:00\nmodified:\t-\ncommand:\tpg_restore \\\n\t  dump.sql\n"
+	if b.String() != expected {
+		t.Errorf("Info was printed incorrect, got %s", b.String())
+	}
+}
+
+func TestPrintHistory(t *testing.T) {
+	l := &Line{
+		cmd:      "ls -l",
+		modified: time.Date(2023, 4, 2, 12, 0, 0, 0, time.Local),
+		history: []revision{
+			{Cmd: "ls -la"},
+			{Cmd: "ls -lah", Modified: time.Date(2023, 4, 1, 12, 0, 0, 0, time.Local)},
+		},
+	}
+	var b bytes.Buffer
+	l.printHistory(&b)
+
+	expected := "rev 0  -\n  ls -la\n\n" +
+		"rev 1  2023-04-01 12:00:00\n- ls -la\n+ ls -lah\n\n" +
+		"rev 2  2023-04-02 12:00:00 (current)\n- ls -lah\n+ ls -l\n"
+	if b.String() != expected {
+		t.Errorf("History was printed incorrect, got %s", b.String())
+	}
+}
+
+func TestWithArgs(t *testing.T) {
+	cases := []struct {
+		cmd      string
+		expected string
+	}{
+		{"go test ./...", "go test ./... -run 'Test Foo' -v"},
+		{"grep $1 file", "grep $1 file"},
+		{`for f in "$@"; do echo $f; done`, `for f in "$@"; do echo $f; done`},
+		{"echo ${1:-x}", "echo ${1:-x}"},
+		{"echo $argv", "echo $argv"},
+		{"echo $HOME", "echo $HOME -run 'Test Foo' -v"},
+		{"for x in a; do\necho $x\ndone", "for x in a; do\necho $x\ndone"},
+		{"awk '{print $1}'", "awk '{print $1}' -run 'Test Foo' -v"},
+		{`awk "{print \$1}"`, `awk "{print \$1}" -run 'Test Foo' -v`},
+		{`sed "s/$1/x/" file`, `sed "s/$1/x/" file`},
+		{`echo 'it''s' "'" $1`, `echo 'it''s' "'" $1`},
+		{"make build; ", "make build; "},
+		{"sleep 10 &", "sleep 10 &"},
+		{"echo hi # greet $1", "echo hi # greet $1"},
+		{"echo a#b '#'", "echo a#b '#' -run 'Test Foo' -v"},
+	}
+	for _, c := range cases {
+		if cmd := (&Line{cmd: c.cmd}).withArgs([]string{"-run", "Test Foo", "-v"}); cmd != c.expected {
+			t.Errorf("Wrong command for %s, got %s", c.cmd, cmd)
+		}
+	}
+	if cmd := (&Line{cmd: "ls"}).withArgs(nil); cmd != "ls" {
+		t.Errorf("Wrong command without arguments, got %s", cmd)
+	}
+}
+
+func TestWarnUnusedArgs(t *testing.T) {
+	rescueStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	(&Line{cmd: "cd /srv\nmake"}).warnUnusedArgs([]string{"all"})
+	(&Line{cmd: "cd /srv\nmake $1"}).warnUnusedArgs([]string{"all"})
+	(&Line{cmd: "make"}).warnUnusedArgs([]string{"all"})
+	(&Line{cmd: "cd /srv\nmake"}).warnUnusedArgs(nil)
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+	os.Stderr = rescueStderr
+
+	if strings.Count(string(out), "\n") != 1 || !strings.HasPrefix(string(out), "rem: arguments not used") {
+		t.Errorf("Wrong warnings, got %s", out)
+	}
+}
+
+func TestExecArgs(t *testing.T) {
+	l := &Line{cmd: "grep $1 file"}
+	if args := strings.Join(l.execArgs("/bin/bash", []string{"a b"}), "|"); args != "/bin/bash|-c|grep $1 file|rem|a b" {
+		t.Errorf("Wrong bash arguments, got %s", args)
+	}
+	if args := strings.Join(l.execArgs("/usr/bin/fish", []string{"a b"}), "|"); args != "/usr/bin/fish|-c|grep $1 file|a b" {
+		t.Errorf("Wrong fish arguments, got %s", args)
+	}
+	if args := strings.Join(l.execArgs("/bin/zsh", nil), "|"); args != "/bin/zsh|-c|grep $1 file" {
+		t.Errorf("Wrong arguments without extra ones, got %s", args)
+	}
+
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not installed")
+	}
+	l = &Line{cmd: `printf '%s|' "$1"; printf '%s|' "$@"`}
+	args := l.execArgs("bash", []string{"a b", "it's"})
+	out, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil || string(out) != "a b|a b|it's|" {
+		t.Errorf("Wrong positional parameters, got %s %v", out, err)
+	}
+}
+
+func useShell(t *testing.T, shell string) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config = defaultConfig()
+	config.Shell = shell
+}
+
+func TestRunChild(t *testing.T) {
+	useShell(t, "/bin/sh")
+
+	cases := map[string]int{"true": 0, "exit 3": 3, "kill -TERM $$": 143}
+	for cmd, expected := range cases {
+		code, err := (&Line{cmd: cmd}).run(false, nil, false)
+		if err != nil || code != expected {
+			t.Errorf("Wrong exit status for %s, got %d %v", cmd, code, err)
+		}
+	}
+
+	useShell(t, "/not/a/shell")
+	if _, err := (&Line{cmd: "true"}).run(false, nil, false); err == nil {
+		t.Error("No error for missing shell.")
+	}
+}
+
+func TestRunChildTiming(t *testing.T) {
+	useShell(t, "/bin/sh")
+
+	rescueStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	code, err := (&Line{cmd: "exit 2"}).run(false, nil, true)
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+	os.Stderr = rescueStderr
+
+	if err != nil || code != 2 || !strings.HasPrefix(string(out), "rem: exit 2 after ") {
+		t.Errorf("Wrong timing output, got %s %d %v", out, code, err)
+	}
+}
+
+func TestRunChildSignal(t *testing.T) {
+	useShell(t, "/bin/sh")
+	ready := path.Join(t.TempDir(), "ready")
+
+	// the command exits with 7 when it gets SIGTERM
+	l := &Line{cmd: "trap 'exit 7' TERM; touch " + ready + "; while true; do sleep 0.05; done"}
+	result := make(chan int)
+	go func() {
+		code, _ := l.run(false, nil, false)
+		result <- code
+	}()
+	for i := 0; i < 200; i++ {
+		if _, err := os.Stat(ready); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	syscall.Kill(os.Getpid(), syscall.SIGTERM)
+
+	select {
+	case code := <-result:
+		if code != 7 {
+			t.Errorf("Signal not forwarded, got exit status %d", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Command still running after SIGTERM.")
+	}
+}
+
+func TestExecuteIndexChild(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	useShell(t, "/bin/sh")
+	rem := getRem(t, "exit 4\ntrue\n")
+	defer removeRemFile(rem)
+	rem.read()
+	rem.child = true
+
+	err := rem.executeIndex(0, nil, nil)
+	if exitErr, ok := err.(*exitError); !ok || exitErr.code != 4 {
+		t.Errorf("Exit status not returned, got %v", err)
+	}
+	if err := rem.executeIndex(1, nil, nil); err != nil {
+		t.Errorf("Error for successful command, got %s", err)
+	}
+}
+
+// Runs a command counting the SIGINTs it gets, terminal sends SIGINT to
+// the command and rem like Ctrl-C does, otherwise only rem gets it.
+func countInterrupts(t *testing.T, terminal bool) string {
+	useShell(t, "/bin/sh")
+	saved := inForeground
+	t.Cleanup(func() { inForeground = saved })
+	inForeground = func() bool { return terminal }
+
+	dir := t.TempDir()
+	ready, stop, count := path.Join(dir, "ready"), path.Join(dir, "stop"), path.Join(dir, "count")
+	l := &Line{cmd: "n=0; trap 'n=$((n+1)); echo $n > " + count + "' INT; echo $$ > " + ready +
+		"; while [ ! -e " + stop + " ]; do sleep 0.05; done"}
+	result := make(chan int)
+	go func() {
+		code, _ := l.run(false, nil, false)
+		result <- code
+	}()
+	var pid []byte
+	for i := 0; i < 200; i++ {
+		if pid, _ = ioutil.ReadFile(ready); len(pid) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	child, err := toInt(strings.TrimSpace(string(pid)))
+	if err != nil {
+		t.Fatalf("Command not started, got %s", pid)
+	}
+
+	// pending signals would merge
+	if terminal {
+		syscall.Kill(child, syscall.SIGINT)
+		time.Sleep(200 * time.Millisecond)
+	}
+	syscall.Kill(os.Getpid(), syscall.SIGINT)
+	time.Sleep(200 * time.Millisecond)
+	ioutil.WriteFile(stop, nil, 0644)
+
+	select {
+	case code := <-result:
+		if code != 0 {
+			t.Errorf("Wrong exit status, got %d", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Command still running.")
+	}
+	got, _ := ioutil.ReadFile(count)
+	return strings.TrimSpace(string(got))
+}
+
+func TestRunChildInterrupt(t *testing.T) {
+	if got := countInterrupts(t, true); got != "1" {
+		t.Errorf("SIGINT of the terminal not delivered once, got %s times", got)
+	}
+}
+
+func TestRunChildInterruptDirect(t *testing.T) {
+	if got := countInterrupts(t, false); got != "1" {
+		t.Errorf("SIGINT to rem not forwarded once, got %s times", got)
+	}
+}
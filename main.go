@@ -17,7 +17,12 @@
 package main
 
 func main() {
-	if err := run(".rem"); err != nil {
+	cfg, err := loadConfig()
+	if err != nil {
+		exit(err)
+	}
+	config = cfg
+	if err := run(config.Filename); err != nil {
 		exit(err)
 	}
 }
@@ -0,0 +1,163 @@
+// rem - A tool to remember things on the command line.
+// Copyright (C) 2015 Martin Borho (martin@borho.net)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var notebookName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Returns the dir notebooks are stored in.
+func notebookDir() string {
+	return path.Join(dataDir(), "notebooks")
+}
+
+// Returns the path of the named notebook.
+func notebookPath(name string) (string, error) {
+	if !notebookName.MatchString(name) || name == "global" || name == "local" {
+		return "", fmt.Errorf("Invalid notebook name %s, use letters, digits, - and _.", name)
+	}
+	return path.Join(notebookDir(), name+".rem"), nil
+}
+
+// Returns the names of all notebooks, sorted.
+func notebooks() ([]string, error) {
+	files, err := filepath.Glob(path.Join(notebookDir(), "*.rem"))
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, file := range files {
+		names = append(names, strings.TrimSuffix(path.Base(file), ".rem"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (f *File) useNotebook(name string) error {
+	// Uses the named notebook as rem file, its dir gets created.
+	p, err := notebookPath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(p), 0700); err != nil {
+		return err
+	}
+	f.path = p
+	return nil
+}
+
+func (r *Rem) printNotebooks() error {
+	// Print all notebooks with their number of lines
+	names, err := notebooks()
+	if err != nil {
+		return err
+	}
+	w := r.getTabWriter()
+	for _, name := range names {
+		notebook := &Rem{}
+		if err := notebook.useNotebook(name); err != nil {
+			return err
+		}
+		if err := notebook.read(); err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		fmt.Fprintf(w, " %s\t%d\n", name, len(notebook.lines))
+	}
+	return w.Flush()
+}
+
+func (r *Rem) collection(name string) (*Rem, error) {
+	// Returns the notebook with the given name, the global rem file for
+	// "global" or the nearest local one for "local".
+	target := r.newLayer("")
+	switch name {
+	case "global":
+		p, err := r.globalPath()
+		if err != nil {
+			return nil, err
+		}
+		target.path = p
+	case "local":
+		if err := target.setPath(); err != nil {
+			return nil, err
+		}
+		if p, err := r.globalPath(); err == nil && p == target.filepath {
+			return nil, errors.New("No local rem file found, create one with here.")
+		}
+		target.path = target.filepath
+	default:
+		if err := target.useNotebook(name); err != nil {
+			return nil, err
+		}
+	}
+	if err := target.setPath(); err != nil {
+		return nil, err
+	}
+	if target.filepath == r.filepath {
+		return nil, errors.New("Source and target are the same.")
+	}
+	return target, nil
+}
+
+func (r *Rem) copyLine(index int, target *Rem) error {
+	// Copies a line with all its metadata to another rem file, it keeps
+	// its id unless the target uses it already.
+	line, err := r.getLine(index)
+	if err != nil {
+		return err
+	}
+	copied := &Line{}
+	copied.fromRecord(line.toRecord())
+	return target.update(func() error {
+		conflict, err := target.tagConflict(copied.tags, -1)
+		if err != nil {
+			return err
+		}
+		if conflict >= 0 {
+			// takes the place of the replaced line
+			target.remember("replace", conflict)
+			copied.id = target.lines[conflict].id
+			target.lines[conflict] = copied
+			return nil
+		}
+		if _, err := target.getIndexByID(copied.id); err == nil {
+			copied.id = target.newID()
+		}
+		target.lines = append(target.lines, copied)
+		return nil
+	})
+}
+
+func (r *Rem) moveLine(index int, target *Rem) error {
+	// Copies a line to another rem file and removes it here.
+	if r.readonly {
+		return errReadonly
+	}
+	if err := r.copyLine(index, target); err != nil {
+		return err
+	}
+	return r.removeLine(index)
+}
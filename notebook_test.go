@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func getNotebook(t *testing.T, name, content string) *Rem {
+	// Creates a notebook in a temp data dir.
+	rem := &Rem{}
+	if err := rem.useNotebook(name); err != nil {
+		t.Fatalf("Error using notebook, got %s", err)
+	}
+	if err := ioutil.WriteFile(rem.path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	rem.read()
+	return rem
+}
+
+func TestNotebookPath(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", "/data")
+	p, err := notebookPath("k8s")
+	if err != nil || p != "/data/rem/notebooks/k8s.rem" {
+		t.Errorf("Wrong notebook path, got %s %v", p, err)
+	}
+	for _, name := range []string{"", "../etc", "a b", "global", "local"} {
+		if _, err := notebookPath(name); err == nil {
+			t.Errorf("No error for notebook name %s", name)
+		}
+	}
+}
+
+func TestPrintNotebooks(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	getNotebook(t, "sql", "select 1\n")
+	getNotebook(t, "k8s", "kubectl get pods\nkubectl get nodes\n")
+
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	rem := &Rem{}
+	err := rem.printNotebooks()
+
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+	os.Stdout = rescueStdout
+
+	if err != nil || string(out) != " k8s  2\n sql  1\n" {
+		t.Errorf("Wrong notebooks output, got %s %v", out, err)
+	}
+	if k8s := getNotebook(t, "k8s", "ls\n"); k8s.label() != "k8s" {
+		t.Errorf("Wrong label, got %s", k8s.label())
+	}
+}
+
+func TestCopyLine(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+	getNotebook(t, "k8s", "#foo#kubectl get pods\necho k8s\n")
+
+	target, err := rem.collection("k8s")
+	if err != nil {
+		t.Fatalf("Error getting notebook, got %s", err)
+	}
+	if err := rem.copyLine(2, target); err != nil {
+		t.Fatalf("Error when copying, got %s", err)
+	}
+	// the tag is used in the notebook already
+	if err := rem.copyLine(1, target); err == nil {
+		t.Error("No error for used tag.")
+	}
+	target.replace = true
+	if err := rem.copyLine(1, target); err != nil {
+		t.Fatalf("Error when copying with replace, got %s", err)
+	}
+
+	target.read()
+	if len(target.lines) != 3 || target.lines[0].cmd != "ls -la" || target.lines[2].cmd != "echo test" {
+		t.Fatalf("Wrong lines in notebook, got %d", len(target.lines))
+	}
+	if target.lines[2].id != rem.lines[2].id {
+		t.Errorf("ID not kept, got %s", target.lines[2].id)
+	}
+	rem.read()
+	if len(rem.lines) != 3 {
+		t.Errorf("Line removed when copying, got %d lines", len(rem.lines))
+	}
+}
+
+func TestMoveLine(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	target, err := rem.collection("sql")
+	if err != nil {
+		t.Fatalf("Error getting notebook, got %s", err)
+	}
+	if err := rem.moveLine(1, target); err != nil {
+		t.Fatalf("Error when moving, got %s", err)
+	}
+	target.read()
+	rem.read()
+	if len(rem.lines) != 2 || len(target.lines) != 1 || target.lines[0].tagList() != "foo" {
+		t.Errorf("Line not moved, got %d %d", len(rem.lines), len(target.lines))
+	}
+	if _, err := os.Stat(path.Join(dataDir(), "notebooks", "sql.rem")); err != nil {
+		t.Errorf("Notebook not created, got %s", err)
+	}
+	if _, err := target.collection("sql"); err == nil {
+		t.Error("No error for same source and target.")
+	}
+}
@@ -0,0 +1,228 @@
+// rem - A tool to remember things on the command line.
+// Copyright (C) 2015 Martin Borho (martin@borho.net)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Placeholders like {{host}} or {{host:=localhost}} with a default.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*(:=([^}]*))?\}\}`)
+
+// Escaped braces, \{{ stays {{ in the command.
+const escapedBraces = `\{{`
+
+var valueName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// A placeholder in a command.
+type placeholder struct {
+	name       string
+	def        string
+	hasDefault bool
+}
+
+// Returns the command with escaped braces masked, nothing in them gets
+// taken as placeholder.
+func (l *Line) maskEscaped() string {
+	return strings.ReplaceAll(l.cmd, escapedBraces, "\x00")
+}
+
+// Checks if the command uses another template syntax with {{ }}, like
+// Go templates of kubectl or docker. Placeholders in it aren't expanded,
+// {{end}} would be taken as one otherwise.
+func (l *Line) hasTemplate() bool {
+	return strings.Contains(placeholderPattern.ReplaceAllString(l.maskEscaped(), ""), "{{")
+}
+
+// Returns the placeholders of the command in order of appearance, each
+// name once, the first default given for a name is used.
+func (l *Line) placeholders() []placeholder {
+	found := []placeholder{}
+	if l.hasTemplate() {
+		return found
+	}
+	seen := map[string]int{}
+	for _, match := range placeholderPattern.FindAllStringSubmatch(l.maskEscaped(), -1) {
+		p := placeholder{name: match[1], def: strings.TrimSpace(match[3]), hasDefault: match[2] != ""}
+		if i, ok := seen[p.name]; ok {
+			if !found[i].hasDefault {
+				found[i] = p
+			}
+			continue
+		}
+		seen[p.name] = len(found)
+		found = append(found, p)
+	}
+	return found
+}
+
+// Parses arguments like ns=staging into values for placeholders, the
+// arguments after "--" are returned to be passed to the command.
+func parseValues(args []string) (map[string]string, []string, error) {
+	values := map[string]string{}
+	for i, arg := range args {
+		if arg == "--" {
+			return values, args[i+1:], nil
+		}
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || !valueName.MatchString(parts[0]) {
+			return nil, nil, fmt.Errorf("Invalid argument %s, use name=value or pass it to the command after --.", arg)
+		}
+		values[parts[0]] = parts[1]
+	}
+	return values, []string{}, nil
+}
+
+// Returns the value for a placeholder from the given values or the
+// environment.
+func lookupValue(p placeholder, values map[string]string) (string, bool) {
+	if value, ok := values[p.name]; ok {
+		return value, true
+	}
+	return os.LookupEnv(p.name)
+}
+
+// Returns the value quoted for where the placeholder is in the command,
+// values inside quotes which would end them or expand are refused.
+func quoteValue(name, value string, quote byte) (string, error) {
+	switch {
+	case quote == '\'' && strings.Contains(value, "'"):
+		return "", fmt.Errorf("Value for {{%s}} can't be used in single quotes, it contains '.", name)
+	case quote == '"' && strings.ContainsAny(value, "$`\\\""):
+		return "", fmt.Errorf("Value for {{%s}} can't be used in double quotes, it contains $, `, \\ or \".", name)
+	case quote == 0:
+		return shellQuote(value), nil
+	}
+	return value, nil
+}
+
+// Returns the quote the command is in at each byte, ' or " or 0.
+func quoteStates(cmd string) []byte {
+	states := make([]byte, len(cmd))
+	var quote byte
+	for i := 0; i < len(cmd); i++ {
+		states[i] = quote
+		switch c := cmd[i]; {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			}
+		case c == '\\':
+			if i+1 < len(cmd) {
+				states[i+1] = quote
+			}
+			i++
+		case c == '\'' && quote == 0, c == '"' && quote == 0:
+			quote = c
+		case c == '"' && quote == '"':
+			quote = 0
+		}
+	}
+	return states
+}
+
+// Returns the command with its placeholders replaced and escaped braces
+// unescaped. Values come from the given ones, the environment or are
+// asked for on the terminal if interactive, they get quoted and never
+// run as shell code. Defaults are part of the command and used as they
+// are.
+func (l *Line) expand(values map[string]string, interactive bool) (string, error) {
+	placeholders := l.placeholders()
+	if err := l.checkValues(placeholders, values); err != nil {
+		return "", err
+	}
+	resolved := map[string]string{}
+	quoted := map[string]bool{}
+	missing := []string{}
+	var stdin *bufio.Reader
+	for _, p := range placeholders {
+		if value, ok := lookupValue(p, values); ok {
+			resolved[p.name], quoted[p.name] = value, true
+			continue
+		}
+		if p.hasDefault {
+			resolved[p.name] = p.def
+			continue
+		}
+		if !interactive {
+			missing = append(missing, p.name)
+			continue
+		}
+		if stdin == nil {
+			stdin = bufio.NewReader(os.Stdin)
+		}
+		fmt.Fprintf(os.Stderr, "%s: ", p.name)
+		value, _ := stdin.ReadString('\n')
+		if value = strings.TrimSpace(value); value == "" {
+			return "", fmt.Errorf("No value given for {{%s}}.", p.name)
+		}
+		resolved[p.name], quoted[p.name] = value, true
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("Missing values for {{%s}}, pass them like %s=value.", strings.Join(missing, "}}, {{"), missing[0])
+	}
+
+	cmd := l.maskEscaped()
+	if len(placeholders) > 0 {
+		states := quoteStates(cmd)
+		var buf strings.Builder
+		last := 0
+		for _, m := range placeholderPattern.FindAllStringSubmatchIndex(cmd, -1) {
+			name := cmd[m[2]:m[3]]
+			value := resolved[name]
+			if quoted[name] {
+				var err error
+				if value, err = quoteValue(name, value, states[m[0]]); err != nil {
+					return "", err
+				}
+			}
+			buf.WriteString(cmd[last:m[0]])
+			buf.WriteString(value)
+			last = m[1]
+		}
+		buf.WriteString(cmd[last:])
+		cmd = buf.String()
+	}
+	return strings.ReplaceAll(cmd, "\x00", "{{"), nil
+}
+
+// Checks that every given value has a placeholder to fill.
+func (l *Line) checkValues(placeholders []placeholder, values map[string]string) error {
+	names := map[string]bool{}
+	for _, p := range placeholders {
+		names[p.name] = true
+	}
+	unused := []string{}
+	for name := range values {
+		if !names[name] {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) == 0 {
+		return nil
+	}
+	sort.Strings(unused)
+	if l.hasTemplate() {
+		return fmt.Errorf("No placeholders expanded, the command uses {{ }} for another template. Escape its braces as \\{{ to use {{%s}}.", unused[0])
+	}
+	return fmt.Errorf("The command has no placeholder {{%s}}.", strings.Join(unused, "}}, {{"))
+}
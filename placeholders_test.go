@@ -0,0 +1,163 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlaceholders(t *testing.T) {
+	l := &Line{cmd: "ssh {{ user }}@{{host:=localhost}} -p {{port:= 22 }} 'kubectl -n {{ns}} get {{ns}}'"}
+	found := l.placeholders()
+	expected := []placeholder{{"user", "", false}, {"host", "localhost", true}, {"port", "22", true}, {"ns", "", false}}
+	if len(found) != len(expected) {
+		t.Fatalf("Wrong placeholders, got %+v", found)
+	}
+	for i := range expected {
+		if found[i] != expected[i] {
+			t.Errorf("Wrong placeholder %d, got %+v", i, found[i])
+		}
+	}
+	if found := (&Line{cmd: "echo ${HOME} {{1x}} {x}"}).placeholders(); len(found) != 0 {
+		t.Errorf("Placeholders found, got %+v", found)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	values, args, err := parseValues([]string{"ns=staging", "query=a=b", "empty="})
+	if err != nil || values["ns"] != "staging" || values["query"] != "a=b" || values["empty"] != "" || len(args) != 0 {
+		t.Errorf("Wrong values, got %v %v %v", values, args, err)
+	}
+	values, args, err = parseValues([]string{"ns=prod", "--", "-run", "x=y", "--"})
+	if err != nil || len(values) != 1 || len(args) != 3 || args[1] != "x=y" || args[2] != "--" {
+		t.Errorf("Wrong arguments, got %v %v %v", values, args, err)
+	}
+	for _, arg := range []string{"staging", "=x", "1ns=x", "-v"} {
+		if _, _, err := parseValues([]string{arg}); err == nil {
+			t.Errorf("No error for %s", arg)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	t.Setenv("REM_TEST_NS", "from-env")
+	l := &Line{cmd: "kubectl -n {{REM_TEST_NS}} --context {{ctx:=dev}} logs {{pod}}"}
+
+	cmd, err := l.expand(map[string]string{"pod": "web-1"}, false)
+	if err != nil || cmd != "kubectl -n from-env --context dev logs web-1" {
+		t.Errorf("Wrong expanded command, got %s %v", cmd, err)
+	}
+	cmd, err = l.expand(map[string]string{"pod": "web-1", "REM_TEST_NS": "prod", "ctx": "live"}, false)
+	if err != nil || cmd != "kubectl -n prod --context live logs web-1" {
+		t.Errorf("Arguments not preferred, got %s %v", cmd, err)
+	}
+	if _, err := l.expand(map[string]string{}, false); err == nil || err.Error() != "Missing values for {{pod}}, pass them like pod=value." {
+		t.Errorf("Wrong error for missing value, got %v", err)
+	}
+}
+
+func TestExpandQuoting(t *testing.T) {
+	t.Setenv("REM_TEST_CMD", "$(id)")
+	cases := []struct {
+		cmd, value, expected string
+	}{
+		{"echo hello {{name}}", "a b; id", "echo hello 'a b; id'"},
+		{"echo hello {{name}}", "$(whoami)", "echo hello '$(whoami)'"},
+		{"echo hello {{name}}", "it's", `echo hello 'it'\''s'`},
+		{"echo --name={{name}}", "a b", "echo --name='a b'"},
+		{"echo 'hello {{name}}'", "a b; $(id)", "echo 'hello a b; $(id)'"},
+		{`echo "hello {{name}}"`, "a b; id", `echo "hello a b; id"`},
+		{`echo "it's" {{name}}`, "a b", `echo "it's" 'a b'`},
+		{`echo \' {{name}}`, "a b", `echo \' 'a b'`},
+	}
+	for _, c := range cases {
+		cmd, err := (&Line{cmd: c.cmd}).expand(map[string]string{"name": c.value}, false)
+		if err != nil || cmd != c.expected {
+			t.Errorf("Wrong expanded command for %s, got %s %v", c.cmd, cmd, err)
+		}
+	}
+
+	// values which would end the quotes or expand in them
+	refused := [][2]string{
+		{"echo 'hello {{name}}'", "it's"},
+		{`echo "hello {{name}}"`, "$(whoami)"},
+		{`echo "hello {{name}}"`, `a"; id; "`},
+		{`echo "{{REM_TEST_CMD}}"`, ""},
+	}
+	for _, c := range refused {
+		values := map[string]string{"name": c[1]}
+		if c[1] == "" {
+			values = map[string]string{}
+		}
+		if expanded, err := (&Line{cmd: c[0]}).expand(values, false); err == nil {
+			t.Errorf("No error for %s in %s, got %s", c[1], c[0], expanded)
+		}
+	}
+
+	// defaults are part of the command
+	if cmd, err := (&Line{cmd: "ls {{opts:=-l -a}}"}).expand(map[string]string{}, false); err != nil || cmd != "ls -l -a" {
+		t.Errorf("Default changed, got %s %v", cmd, err)
+	}
+}
+
+func TestExpandUnusedValues(t *testing.T) {
+	if _, err := (&Line{cmd: "kubectl -n {{ns}} get pods"}).expand(map[string]string{"ns": "x", "nz": "y"}, false); err == nil ||
+		err.Error() != "The command has no placeholder {{nz}}." {
+		t.Errorf("Wrong error for unknown value, got %v", err)
+	}
+	kt := &Line{cmd: "kubectl -n {{ns}} get pods -o go-template='{{range .items}}{{.metadata.name}}{{end}}'"}
+	if _, err := kt.expand(map[string]string{"ns": "x"}, false); err == nil || !strings.Contains(err.Error(), "another template") {
+		t.Errorf("Wrong error for value of Go template command, got %v", err)
+	}
+	if cmd, err := (&Line{cmd: "ls"}).expand(map[string]string{}, false); err != nil || cmd != "ls" {
+		t.Errorf("Error without values, got %s %v", cmd, err)
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	// Go templates are left alone
+	tmpl := `kubectl get pods -o go-template='{{range .items}}{{.metadata.name}}{{"\n"}}{{end}}'`
+	l := &Line{cmd: tmpl}
+	if found := l.placeholders(); len(found) != 0 {
+		t.Errorf("Placeholders in Go template, got %+v", found)
+	}
+	if cmd, err := l.expand(map[string]string{}, false); err != nil || cmd != tmpl {
+		t.Errorf("Go template changed, got %s %v", cmd, err)
+	}
+
+	// escaped braces to mix them with placeholders
+	l = &Line{cmd: `docker inspect -f '\{{.State.Status}} \{{end}}' {{name}}`}
+	if cmd, err := l.expand(map[string]string{"name": "web"}, false); err != nil || cmd != "docker inspect -f '{{.State.Status}} {{end}}' web" {
+		t.Errorf("Wrong command with escaped braces, got %s %v", cmd, err)
+	}
+}
+
+func TestExpandPrompt(t *testing.T) {
+	l := &Line{cmd: "ping -c {{count}} {{host}}"}
+
+	funcDefer, err := mockStdin(t, "3\nexample.org\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer funcDefer()
+	cmd, err := l.expand(map[string]string{}, true)
+	if err != nil || cmd != "ping -c 3 example.org" {
+		t.Errorf("Values not prompted, got %s %v", cmd, err)
+	}
+	if _, err := l.expand(map[string]string{}, true); err == nil {
+		t.Error("No error for empty answer.")
+	}
+}
+
+func TestExpandIndex(t *testing.T) {
+	rem := getRem(t, "#deploy#helm upgrade app --namespace {{ns}}\n")
+	defer removeRemFile(rem)
+	rem.read()
+
+	cmd, err := rem.expandIndex(0, map[string]string{"ns": "staging"})
+	if err != nil || cmd != "helm upgrade app --namespace staging" {
+		t.Errorf("Wrong expanded command, got %s %v", cmd, err)
+	}
+	if rem.lines[0].cmd != "helm upgrade app --namespace {{ns}}" {
+		t.Errorf("Stored command changed, got %s", rem.lines[0].cmd)
+	}
+}
@@ -20,39 +20,146 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
+)
+
+var (
+	errTagNotFound = errors.New("Tag not found.")
+	errNotFound    = errors.New("Tag or ID not found.")
+	errReadonly    = errors.New("Shared commands are read-only.")
 )
 
 type Rem struct {
-	path            string
 	lines           []*Line
 	hasTags         bool
+	legacy          bool
 	printBeforeExec bool
+	child           bool
+	timing          bool
+	force           bool
+	replace         bool
+	readonly        bool
+	sortBy          string
+	usage           map[string]*usage
+	layers          []*Rem
+	shared          *Rem
+	pending         *change
 	File
 }
 
+func (r *Rem) addLine(line *Line) error {
+	// Append line with id, timestamps and author to the history file,
+	// with replace set a line having one of its tags gets replaced.
+	line.author = currentAuthor()
+	return r.update(func() error {
+		conflict, err := r.tagConflict(line.tags, -1)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if conflict >= 0 {
+			r.remember("replace", conflict)
+			existing := r.lines[conflict]
+			existing.cmd = line.cmd
+			existing.tags = line.tags
+			existing.description = line.description
+			existing.modified = now
+			return nil
+		}
+		line.id = r.newID()
+		line.created = now
+		line.modified = now
+		r.lines = append(r.lines, line)
+		return nil
+	})
+}
+
 func (r *Rem) appendLine(line, tag string) error {
 	// Append line to the history file
-	r.setFile(true)
-	defer r.Close()
+	return r.addLine(&Line{cmd: line, tags: parseTags(tag)})
+}
+
+func (r *Rem) tagConflict(tags []string, index int) (int, error) {
+	// Returns the index of another line already using one of the tags
+	// or -1. Fails if the tags can't be used, replace allows one conflict.
+	conflict := -1
+	for _, tag := range tags {
+		if err := checkTag(tag); err != nil {
+			return -1, err
+		}
+		for i, line := range r.lines {
+			if i == index || !line.hasTag(tag) {
+				continue
+			}
+			if !r.replace {
+				return -1, fmt.Errorf("Tag %s is already used by line %d, use --replace to replace it.", tag, i)
+			}
+			if conflict >= 0 && conflict != i {
+				return -1, fmt.Errorf("Tags are used by lines %d and %d, can't replace both.", conflict, i)
+			}
+			conflict = i
+		}
+	}
+	return conflict, nil
+}
 
-	if tag != "" {
-		line = fmt.Sprintf("#%s#%s\n", tag, line)
-	} else {
-		line = fmt.Sprintf("%s\n", line)
+func (r *Rem) retag(index int, tags []string) error {
+	// Sets the tags of a line, with replace the tags are taken away
+	// from other lines using them.
+	seen, _ := r.getLine(index)
+	return r.update(func() error {
+		if err := r.checkUnchanged(index, seen); err != nil {
+			return err
+		}
+		line, err := r.getLine(index)
+		if err != nil {
+			return err
+		}
+		for _, tag := range tags {
+			if err := checkTag(tag); err != nil {
+				return err
+			}
+			for i, other := range r.lines {
+				if i == index || !other.hasTag(tag) {
+					continue
+				}
+				if !r.replace {
+					return fmt.Errorf("Tag %s is already used by line %d, use --replace to move it.", tag, i)
+				}
+				r.remember("tag", i)
+				other.removeTag(tag)
+			}
+		}
+		r.remember("tag", index)
+		line.tags = tags
+		line.modified = time.Now()
+		return nil
+	})
+}
+
+func (r *Rem) checkUnchanged(index int, seen *Line) error {
+	// Refuses to write when the rem file was changed since it was read
+	// and the line at index isn't the one seen before anymore.
+	if !r.changed {
+		return nil
 	}
-	//if _, err := r.WriteString(line); err != nil {
-	if err := r.write(line); err != nil {
-		panic(err)
+	line, err := r.getLine(index)
+	if err != nil || seen == nil || line.cmd != seen.cmd || line.tagList() != seen.tagList() {
+		return errors.New("Rem file was changed in the meantime, nothing was written. Please check and try again.")
 	}
 	return nil
 }
 
 func (r *Rem) editIndex(index int) error {
+	if r.readonly {
+		return errReadonly
+	}
 	line, err := r.getLine(index)
 	if err != nil {
 		return err
@@ -63,62 +170,105 @@ func (r *Rem) editIndex(index int) error {
 	}
 	if edited != "" {
 		fmt.Println(edited)
-		r.replaceLine(index, edited)
+		return r.replaceLine(index, edited)
 	}
 	return nil
 }
 
-func (r *Rem) editTag(tag string) error {
-	index, err := r.getIndexByTag(tag)
+func (r *Rem) executeIndex(index int, values map[string]string, args []string) error {
+	cmd, err := r.expandIndex(index, values)
 	if err != nil {
 		return err
 	}
-	return r.editIndex(index)
-}
-
-func (r *Rem) executeIndex(index int) error {
-	line, err := r.getLine(index)
+	line, _ := r.getLine(index)
+	expanded := *line
+	expanded.cmd = cmd
+	expanded.warnUnusedArgs(args)
+	if !r.child && !r.timing {
+		return expanded.execute(r.printBeforeExec, args)
+	}
+	start := time.Now()
+	code, err := expanded.run(r.printBeforeExec, args, r.timing)
 	if err != nil {
 		return err
 	}
-	return line.execute(r.printBeforeExec)
+	cwd, _ := os.Getwd()
+	logErr := appendExecLog(&execRecord{
+		ID:       line.id,
+		Tags:     line.tags,
+		Cmd:      expanded.withArgs(args),
+		Cwd:      cwd,
+		File:     r.filepath,
+		Start:    start,
+		Duration: time.Since(start).Seconds(),
+		Exit:     code,
+		User:     currentUser(),
+	})
+	if logErr != nil {
+		fmt.Fprintf(os.Stderr, "rem: command not logged, %s\n", logErr)
+	}
+	if code != 0 {
+		return &exitError{code: code}
+	}
+	return nil
 }
 
-func (r *Rem) executeTag(tag string) error {
-	index, err := r.getIndexByTag(tag)
+func (r *Rem) expandIndex(index int, values map[string]string) (string, error) {
+	// Returns the command with its placeholders filled in, missing
+	// values are asked for if stdin is a terminal.
+	line, err := r.getLine(index)
 	if err != nil {
-		return err
+		return "", err
 	}
-	return r.executeIndex(index)
+	return line.expand(values, isTerminal(os.Stdin))
 }
 
 func (r *Rem) filterLines(filter string) error {
 	// Print lines filtered by string (regular expression).
-	for x, line := range r.lines {
-		matched, err := regexp.MatchString("(?i)"+filter, line.cmd)
-		if err != nil {
-			return nil
-		}
-		if matched {
-			fmt.Printf(" %d  %s\n", x, line.cmd)
-		}
+	re, err := regexp.Compile("(?i)" + filter)
+	if err != nil {
+		return nil
 	}
+	r.printMatching(func(line *Line) bool {
+		return re.MatchString(line.cmd)
+	}, func(w io.Writer, layer *Rem, x int, line *Line) {
+		fmt.Fprintf(w, " %d  %s  %s\n", x, line.id, line.summary())
+	})
 	return nil
 }
 
 func (r *Rem) getIndexByTag(tag string) (int, error) {
-	// Returns index by tag.
+	// Returns index by tag, fails if more than one line has the tag.
+	found := []string{}
+	index := 0
+	for i, line := range r.lines {
+		if line.hasTag(tag) {
+			found = append(found, strconv.Itoa(i))
+			index = i
+		}
+	}
+	switch len(found) {
+	case 0:
+		return 0, errTagNotFound
+	case 1:
+		return index, nil
+	}
+	return 0, fmt.Errorf("Tag %s is ambiguous, it matches lines %s. Use index or id.", tag, strings.Join(found, ", "))
+}
+
+func (r *Rem) getIndexByID(id string) (int, error) {
+	// Returns index by entry ID.
 	for i, line := range r.lines {
-		if line.tag == tag {
+		if line.id == id {
 			return i, nil
 		}
 	}
-	return 0, errors.New("Tag not found.")
+	return 0, errors.New("ID not found.")
 }
 
 func (r *Rem) getLine(index int) (*Line, error) {
 	// Returns command by index.
-	if len(r.lines) <= index {
+	if index < 0 || len(r.lines) <= index {
 		return nil, errors.New("Index out of range.")
 	}
 	return r.lines[index], nil
@@ -130,14 +280,72 @@ func (r *Rem) getTabWriter() *tabwriter.Writer {
 }
 
 func (r *Rem) printAllLines() {
-	// Print saved lines enumerated
+	// Print saved lines enumerated, ignore tags if no tags are present
+	r.printMatching(func(line *Line) bool {
+		return true
+	}, func(w io.Writer, layer *Rem, x int, line *Line) {
+		line.print(w, x, layer.hasTags)
+	})
+}
+
+func (r *Rem) printMatching(match func(*Line) bool, print func(io.Writer, *Rem, int, *Line)) {
+	// Prints the matching lines of all layers and remembers them as
+	// listed, grouped by layer if there are several.
+	for _, layer := range r.allLayers() {
+		shown := []int{}
+		for x, line := range layer.lines {
+			if match(line) {
+				shown = append(shown, x)
+			}
+		}
+		r.sortIndexes(layer.lines, shown)
+		if len(r.allLayers()) > 1 {
+			if len(shown) == 0 {
+				continue
+			}
+			layer.printHeader(os.Stdout)
+		}
+		w := r.getTabWriter()
+		for _, x := range shown {
+			print(w, layer, x, layer.lines[x])
+		}
+		w.Flush()
+		layer.recordListing(shown)
+	}
+}
+
+func (r *Rem) printInfo(index int) error {
+	// Print command with all its metadata
+	line, err := r.getLine(index)
+	if err != nil {
+		return err
+	}
 	w := r.getTabWriter()
+	line.printInfo(w, index)
+	return w.Flush()
+}
 
-	// print out, ignore tags if no tags are present
-	for x, line := range r.lines {
-		line.print(w, x, r.hasTags)
+func (r *Rem) printHistory(index int) error {
+	// Print all versions of a command with diffs
+	line, err := r.getLine(index)
+	if err != nil {
+		return err
 	}
-	w.Flush()
+	line.printHistory(os.Stdout)
+	return nil
+}
+
+func (r *Rem) revert(index, rev int) error {
+	// Sets the command back to a previous version, the current
+	// version is kept in the history.
+	line, err := r.getLine(index)
+	if err != nil {
+		return err
+	}
+	if rev < 0 || rev >= len(line.history) {
+		return fmt.Errorf("Revision %d not found, the command has %d previous versions.", rev, len(line.history))
+	}
+	return r.replaceLine(index, line.history[rev].Cmd)
 }
 
 func (r *Rem) printLine(index int) error {
@@ -151,84 +359,177 @@ func (r *Rem) printLine(index int) error {
 }
 
 func (r *Rem) printTag(tag string) error {
-	for _, line := range r.lines {
-		if line.tag == tag {
-			fmt.Println(line.cmd)
-			return nil
+	index, err := r.getIndexByTag(tag)
+	if err != nil {
+		return err
+	}
+	return r.printLine(index)
+}
+
+func (r *Rem) printTagged(tags []string) {
+	// Print lines having one of the given tags
+	r.printMatching(func(line *Line) bool {
+		for _, tag := range tags {
+			if line.hasTag(tag) {
+				return true
+			}
+		}
+		return false
+	}, func(w io.Writer, layer *Rem, x int, line *Line) {
+		line.print(w, x, true)
+	})
+}
+
+func (r *Rem) newID() string {
+	// Returns a random ID not used by any line yet.
+	for {
+		id := newID()
+		if _, err := r.getIndexByID(id); err != nil {
+			return id
 		}
 	}
-	return errors.New("Tag not found.")
 }
 
 func (r *Rem) read() error {
-	r.setPath()
-	// Read lines from the history file.
-	lines := []*Line{}
+	// Read lines from the history file, a missing one has no lines.
+	if err := r.setPath(); err != nil {
+		return err
+	}
 
 	// read history
-	r.setFile(false)
+	if err := r.setFile(false); os.IsNotExist(err) {
+		r.lines = []*Line{}
+		return nil
+	} else if err != nil {
+		return err
+	}
 	defer r.Close()
 
-	// read lines
-	scanner := bufio.NewScanner(r.file)
-	for scanner.Scan() {
-		// parse line
-		l := &Line{}
-		l.read(scanner.Text())
-		lines = append(lines, l)
-
-		// tags in files?
-		if l.tag != "" {
-			r.hasTags = true
-		}
+	data, err := r.readAll()
+	if err != nil {
+		return err
 	}
 
-	if err := scanner.Err(); err != nil {
+	// parse lines, legacy files get upgraded on the next write
+	lines, legacy, err := decodeLines(data)
+	if err != nil {
 		return err
 	}
+
+	// tags in files?
+	r.hasTags = false
+	for i, l := range lines {
+		if len(l.tags) > 0 {
+			r.hasTags = true
+		}
+		if l.id == "" {
+			l.deriveID(i)
+		}
+	}
 	r.lines = lines
+	r.legacy = legacy
 	return nil
 }
 
-func (r *Rem) replaceLine(index int, edited string) error {
-	lines := []string{}
-	for i, line := range r.lines {
-		if i == index {
-			newLine := ""
-			if line.tag != "" {
-				newLine = fmt.Sprintf("#%s#%s", line.tag, edited)
-			} else {
-				newLine = edited
-			}
-			lines = append(lines, newLine)
-		} else {
-			lines = append(lines, line.line)
+func (r *Rem) resolve(ref string) (int, error) {
+	// Returns the index of a line addressed by index, ID or tag.
+	if ref == "" {
+		return 0, errors.New("Need index number, id or tag.")
+	}
+	if index, err := toInt(ref); err == nil {
+		if _, err := r.getLine(index); err != nil {
+			return 0, err
 		}
+		return index, r.checkListed(index)
+	}
+	if index, err := r.getIndexByID(ref); err == nil {
+		return index, nil
 	}
-	newLines := []byte{}
-	if len(lines) > 0 {
-		newLines = append([]byte(strings.Join(lines, "\n")), byte('\n'))
+	index, err := r.getIndexByTag(ref)
+	if err == errTagNotFound {
+		return 0, errNotFound
 	}
-	return ioutil.WriteFile(r.filepath, newLines, 0644)
+	return index, err
+}
+
+func (r *Rem) replaceLine(index int, edited string) error {
+	// Replaces the command of the line at given index.
+	seen, _ := r.getLine(index)
+	return r.update(func() error {
+		if err := r.checkUnchanged(index, seen); err != nil {
+			return err
+		}
+		line, err := r.getLine(index)
+		if err != nil {
+			return err
+		}
+		if line.cmd == edited {
+			return nil
+		}
+		r.remember("edit", index)
+		line.history = append(line.history, revision{Cmd: line.cmd, Modified: line.modified})
+		line.cmd = edited
+		line.modified = time.Now()
+		return nil
+	})
 }
 
 func (r *Rem) removeLine(index int) error {
 	// Removes a line from the rem file at given index.
-	lines := []string{}
-	// check line exists
-	if index >= len(r.lines) {
-		return errors.New("Line does not exist!")
+	seen, _ := r.getLine(index)
+	return r.update(func() error {
+		// check line exists
+		if index >= len(r.lines) {
+			return errors.New("Line does not exist!")
+		}
+		if err := r.checkUnchanged(index, seen); err != nil {
+			return err
+		}
+		r.remember("rm", index)
+		r.lines = append(r.lines[:index:index], r.lines[index+1:]...)
+		return nil
+	})
+}
+
+func (r *Rem) save() error {
+	// Writes all lines to the rem file in the current format.
+	data, err := encodeLines(r.lines)
+	if err != nil {
+		return err
 	}
-	// build new slices
-	for _, line := range append(r.lines[:index], r.lines[index+1:]...) {
-		lines = append(lines, line.line)
+	if err := r.writeFile(data); err != nil {
+		return err
 	}
-	newLines := []byte{}
-	if len(lines) > 0 {
-		newLines = append([]byte(strings.Join(lines, "\n")), byte('\n'))
+	r.legacy = false
+	return nil
+}
+
+func (r *Rem) update(fn func() error) error {
+	// Re-reads the rem file while holding the lock, lets fn modify
+	// the lines and writes them back before the lock is released.
+	// Lines remembered by fn get journaled.
+	if r.readonly {
+		return errReadonly
+	}
+	if err := r.setPath(); err != nil {
+		return err
+	}
+	if err := r.lock(); err != nil {
+		return err
+	}
+	defer r.unlock()
+
+	r.pending = nil
+	if err := r.read(); err != nil {
+		return err
 	}
-	err := ioutil.WriteFile(r.filepath, newLines, 0644)
-	return err
+	if err := fn(); err != nil {
+		return err
+	}
+	if err := r.save(); err != nil {
+		return err
+	}
+	return r.journalPending()
 }
 
 func (r *Rem) readFromStdIn() string {
@@ -244,3 +545,12 @@ func (r *Rem) readFromStdIn() string {
 	}
 	return strings.Join(lines, " ")
 }
+
+func (r *Rem) readAllFromStdIn() (string, error) {
+	// Reads stdin until EOF, keeps newlines for multi-line commands.
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(strings.ReplaceAll(string(input), "\r\n", "\n")), nil
+}
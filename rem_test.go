@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -13,13 +17,20 @@ var (
 
 func init() {
 	testRemFile = ".rem_test"
+	// shared commands of the system shouldn't show up
+	config.SharedPath = ""
 }
 
 func removeRemFile(r *Rem) {
 	removeTestFile(r.file)
+	os.Remove(r.journalPath())
 }
 
 func getRem(t *testing.T, remStr string) *Rem {
+	// listings of other tests shouldn't be checked
+	t.Setenv("TMPDIR", t.TempDir())
+	t.Setenv("REM_SHARED_PATH", "")
+
 	cmds := []byte(remStr)
 	if err := ioutil.WriteFile(testRemFile, cmds, 0644); err != nil {
 		t.Fatalf("Cannot create rem testfile, %s", err)
@@ -139,11 +150,182 @@ func TestPrintAllLines(t *testing.T) {
 	out, _ := ioutil.ReadAll(r)
 	os.Stdout = rescueStdout
 
-	if string(out) != " 0   -   ls\n 1  foo  ls -la\n 2   -   echo test\n" {
+	if string(out) != " 0  qebpwy   -   ls\n 1  rwz6fj  foo  ls -la\n 2  kft8sg   -   echo test\n" {
+		t.Errorf("Wrong line output, got %s", out)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	cases := map[string]int{"2": 2, "foo": 1, "kft8sg": 2, "qebpwy": 0}
+	for ref, expected := range cases {
+		index, err := rem.resolve(ref)
+		if err != nil || index != expected {
+			t.Errorf("Wrong index for %s, want %d, got %d, %v", ref, expected, index, err)
+		}
+	}
+	for _, ref := range []string{"3", "-1", "bar", ""} {
+		if _, err := rem.resolve(ref); err == nil {
+			t.Errorf("No error for %s", ref)
+		}
+	}
+}
+
+func TestStableIDs(t *testing.T) {
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	if err := rem.appendLine("pwd", ""); err != nil {
+		t.Fatalf("Error when appending line, got %s", err)
+	}
+	id := rem.lines[3].id
+	if err := rem.removeLine(0); err != nil {
+		t.Fatalf("Error when removing line, got %s", err)
+	}
+
+	// ids stay the same although the indexes changed
+	rem = &Rem{File: File{filename: testRemFile}}
+	rem.read()
+	if index, err := rem.resolve(id); err != nil || index != 2 {
+		t.Errorf("Appended line not found by id, got %d, %v", index, err)
+	}
+	if index, err := rem.resolve("kft8sg"); err != nil || index != 1 {
+		t.Errorf("Line not found by id, got %d, %v", index, err)
+	}
+}
+
+func TestTagAmbiguous(t *testing.T) {
+	rem := getRem(t, "#db,backup#pg_dump prod\n#db#psql prod\n#web#curl localhost\n")
+	defer removeRemFile(rem)
+	rem.read()
+
+	if index, err := rem.resolve("backup"); err != nil || index != 0 {
+		t.Errorf("Tag not resolved, got %d, %v", index, err)
+	}
+	_, err := rem.resolve("db")
+	if err == nil || err.Error() != "Tag db is ambiguous, it matches lines 0, 1. Use index or id." {
+		t.Errorf("No ambiguity error, got %v", err)
+	}
+	if _, err := rem.resolve("prod"); err == nil || err.Error() != "Tag or ID not found." {
+		t.Errorf("Wrong error for unknown tag, got %v", err)
+	}
+}
+
+func TestPrintTagged(t *testing.T) {
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	rem := getRem(t, "#db,backup#pg_dump prod\n#web#curl localhost\n#db#psql prod\n")
+	defer removeRemFile(rem)
+	rem.read()
+
+	rem.printTagged([]string{"db"})
+
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+	os.Stdout = rescueStdout
+
+	expected := fmt.Sprintf(" 0  %s  db,backup  pg_dump prod\n 2  %s  db         psql prod\n", rem.lines[0].id, rem.lines[2].id)
+	if string(out) != expected {
 		t.Errorf("Wrong line output, got %s", out)
 	}
 }
 
+func TestAddDuplicateTag(t *testing.T) {
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	if err := rem.appendLine("ls -lah", "foo"); err == nil {
+		t.Error("Duplicate tag was added.")
+	}
+	if err := rem.appendLine("ls -lah", "1"); err == nil {
+		t.Error("Number was added as tag.")
+	}
+
+	rem.replace = true
+	if err := rem.appendLine("ls -lah", "foo,bar"); err != nil {
+		t.Errorf("Error when replacing tagged line, got %s", err)
+	}
+	if len(rem.lines) != 3 || rem.lines[1].cmd != "ls -lah" || rem.lines[1].tagList() != "foo,bar" {
+		t.Errorf("Tagged line not replaced, got %d lines, %+v", len(rem.lines), rem.lines[1])
+	}
+	if rem.lines[1].id != "rwz6fj" {
+		t.Errorf("Id of replaced line changed, got %s", rem.lines[1].id)
+	}
+}
+
+func TestRetag(t *testing.T) {
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	if err := rem.retag(0, []string{"list", "short"}); err != nil {
+		t.Errorf("Error when tagging line, got %s", err)
+	}
+	if err := rem.retag(2, []string{"foo"}); err == nil {
+		t.Error("Used tag was set again.")
+	}
+
+	// move tag to another line
+	rem.replace = true
+	if err := rem.retag(2, []string{"short"}); err != nil {
+		t.Errorf("Error when moving tag, got %s", err)
+	}
+	if err := rem.retag(1, []string{}); err != nil {
+		t.Errorf("Error when removing tags, got %s", err)
+	}
+
+	rem = &Rem{File: File{filename: testRemFile}}
+	rem.read()
+	for i, expected := range []string{"list", "", "short"} {
+		if rem.lines[i].tagList() != expected {
+			t.Errorf("Wrong tags for line %d, want %s, got %s", i, expected, rem.lines[i].tagList())
+		}
+	}
+}
+
+func TestHistoryRevert(t *testing.T) {
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	for _, cmd := range []string{"ls -lah", "ls -lah", "ls -l"} {
+		if err := rem.replaceLine(1, cmd); err != nil {
+			t.Fatalf("Error when replacing line, got %s", err)
+		}
+	}
+	if len(rem.lines[1].history) != 2 {
+		t.Fatalf("Wrong history, got %+v", rem.lines[1].history)
+	}
+
+	if err := rem.revert(1, 2); err == nil {
+		t.Error("No error for unknown revision.")
+	}
+	if err := rem.revert(1, 0); err != nil {
+		t.Fatalf("Error when reverting, got %s", err)
+	}
+
+	rem = &Rem{File: File{filename: testRemFile}}
+	rem.read()
+	line := rem.lines[1]
+	if line.cmd != "ls -la" || line.tagList() != "foo" {
+		t.Errorf("Revision not restored, got %s", line.cmd)
+	}
+	cmds := []string{}
+	for _, rev := range line.revisions() {
+		cmds = append(cmds, rev.Cmd)
+	}
+	if strings.Join(cmds, "|") != "ls -la|ls -lah|ls -l|ls -la" {
+		t.Errorf("Wrong revisions, got %v", cmds)
+	}
+}
+
 func TestFilterLines(t *testing.T) {
 	rescueStdout := os.Stdout
 	r, w, _ := os.Pipe()
@@ -159,7 +341,7 @@ func TestFilterLines(t *testing.T) {
 	out, _ := ioutil.ReadAll(r)
 	os.Stdout = rescueStdout
 
-	if string(out) != " 0  ls\n 1  ls -la\n" {
+	if string(out) != " 0  qebpwy  ls\n 1  rwz6fj  ls -la\n" {
 		t.Errorf("Wrong line output, got %s", out)
 	}
 }
@@ -216,8 +398,8 @@ func TestAddLineWithTag(t *testing.T) {
 		t.Errorf("Wrong line output, got %s", out)
 	}
 
-	if rem.lines[3].tag != "test" {
-		t.Errorf("Wrong tag saved, got %s", rem.lines[3].tag)
+	if rem.lines[3].tagList() != "test" {
+		t.Errorf("Wrong tag saved, got %s", rem.lines[3].tagList())
 	}
 }
 
@@ -253,8 +435,8 @@ func TestAddLineWithoutTag(t *testing.T) {
 		t.Errorf("Wrong line output, got %s", out)
 	}
 
-	if rem.lines[3].tag != "" {
-		t.Errorf("Tag was saved, got %s", rem.lines[3].tag)
+	if rem.lines[3].tagList() != "" {
+		t.Errorf("Tag was saved, got %s", rem.lines[3].tagList())
 	}
 }
 
@@ -325,6 +507,199 @@ func TestRemoveLine(t *testing.T) {
 	}
 }
 
+func TestUpgradeLegacyFile(t *testing.T) {
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	if rem.legacy == false {
+		t.Error("Legacy format not detected.")
+	}
+
+	if err := rem.appendLine("#bar#pwd", ""); err != nil {
+		t.Errorf("Error when appending line, got %s", err)
+	}
+
+	data, _ := ioutil.ReadFile(testRemFile)
+	if isVersioned(data) == false {
+		t.Errorf("Rem file was not upgraded, got %s", data)
+	}
+
+	rem = &Rem{
+		File: File{
+			filename: testRemFile,
+			global:   false,
+		},
+	}
+	rem.read()
+	if rem.legacy {
+		t.Error("Upgraded file detected as legacy.")
+	}
+	if len(rem.lines) != 4 {
+		t.Fatalf("Lines lost while upgrading, got %d", len(rem.lines))
+	}
+	if rem.lines[1].tagList() != "foo" || rem.lines[1].cmd != "ls -la" {
+		t.Errorf("Tagged line not upgraded, got %+v", rem.lines[1])
+	}
+	if rem.lines[3].tagList() != "" || rem.lines[3].cmd != "#bar#pwd" {
+		t.Errorf("Tag-like command not stored as-is, got %+v", rem.lines[3])
+	}
+	if rem.lines[3].created.IsZero() {
+		t.Error("Creation time not stored.")
+	}
+}
+
+func TestRemoveChangedLine(t *testing.T) {
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	// another process removes the first line
+	other := &Rem{File: File{filename: testRemFile}}
+	if err := other.removeLine(0); err != nil {
+		t.Fatalf("Error when removing line, got %s", err)
+	}
+
+	// index 1 points to a different line now
+	if err := rem.removeLine(1); err == nil {
+		t.Error("Changed line was removed.")
+	}
+
+	rem = &Rem{File: File{filename: testRemFile}}
+	rem.read()
+	if len(rem.lines) != 2 {
+		t.Errorf("Line was removed, got %d lines", len(rem.lines))
+	}
+}
+
+func TestReplaceLineFileChanged(t *testing.T) {
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	// another process appends a line, line 1 is still the same
+	other := &Rem{File: File{filename: testRemFile}}
+	if err := other.appendLine("pwd", ""); err != nil {
+		t.Fatalf("Error when appending line, got %s", err)
+	}
+	if err := rem.replaceLine(1, "ls -lah"); err != nil {
+		t.Errorf("Error when replacing unchanged line, got %s", err)
+	}
+
+	// the line is replaced by another process while editing
+	other = &Rem{File: File{filename: testRemFile}}
+	other.read()
+	if err := other.replaceLine(1, "ls -l"); err != nil {
+		t.Fatalf("Error when replacing line, got %s", err)
+	}
+	if err := rem.replaceLine(1, "ls -la --color"); err == nil {
+		t.Error("Line changed in the meantime was overwritten.")
+	}
+
+	rem = &Rem{File: File{filename: testRemFile}}
+	rem.read()
+	if len(rem.lines) != 4 || rem.lines[1].cmd != "ls -l" {
+		t.Errorf("Wrong lines after replacing, got %d lines, %s", len(rem.lines), rem.lines[1].cmd)
+	}
+}
+
+func TestConcurrentAddRemove(t *testing.T) {
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	// every goroutine adds one line and removes the first one
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := &Rem{File: File{filename: testRemFile}}
+			if err := r.appendLine(fmt.Sprintf("echo %d", i), ""); err != nil {
+				errs <- err
+			}
+			r = &Rem{File: File{filename: testRemFile}}
+			if err := r.removeLine(0); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Error when modifying concurrently, got %s", err)
+	}
+
+	rem = &Rem{File: File{filename: testRemFile}}
+	if err := rem.read(); err != nil {
+		t.Fatalf("Rem file corrupted, got %s", err)
+	}
+	if len(rem.lines) != 3 {
+		t.Errorf("Lines lost or duplicated, got %d lines", len(rem.lines))
+	}
+}
+
+func TestConcurrentProcesses(t *testing.T) {
+	rem := getTestEmptyRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	// several rem processes appending to the same file
+	procs := 8
+	perProc := 10
+	testBinary, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmds := []*exec.Cmd{}
+	for i := 0; i < procs; i++ {
+		cmd := exec.Command(testBinary, "-test.run=^TestHelperAppendProcess$")
+		cmd.Env = append(os.Environ(),
+			"REM_TEST_APPEND_PROC="+strconv.Itoa(i),
+			"REM_TEST_APPEND_COUNT="+strconv.Itoa(perProc))
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("Cannot start process, got %s", err)
+		}
+		cmds = append(cmds, cmd)
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Errorf("Process failed, got %s", err)
+		}
+	}
+
+	rem = &Rem{File: File{filename: testRemFile}}
+	if err := rem.read(); err != nil {
+		t.Fatalf("Rem file corrupted, got %s", err)
+	}
+	if len(rem.lines) != procs*perProc {
+		t.Errorf("Lines lost, want %d, got %d", procs*perProc, len(rem.lines))
+	}
+	seen := map[string]bool{}
+	for _, line := range rem.lines {
+		if seen[line.cmd] {
+			t.Errorf("Line duplicated: %s", line.cmd)
+		}
+		seen[line.cmd] = true
+	}
+}
+
+// Not a real test, appends lines when started by TestConcurrentProcesses.
+func TestHelperAppendProcess(t *testing.T) {
+	proc := os.Getenv("REM_TEST_APPEND_PROC")
+	if proc == "" {
+		return
+	}
+	count, _ := strconv.Atoi(os.Getenv("REM_TEST_APPEND_COUNT"))
+	for i := 0; i < count; i++ {
+		rem := &Rem{File: File{filename: testRemFile}}
+		if err := rem.appendLine(fmt.Sprintf("echo %s-%d", proc, i), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 func TestReadFromStdIn(t *testing.T) {
 	rem := getTestRem(t)
 	cases := []struct {
@@ -0,0 +1,92 @@
+// rem - A tool to remember things on the command line.
+// Copyright (C) 2015 Martin Borho (martin@borho.net)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"time"
+)
+
+// Listings older than this are not checked anymore.
+const listingMaxAge = 24 * time.Hour
+
+// What was shown of a rem file in the last listing, ids by index.
+type listing struct {
+	Time time.Time         `json:"time"`
+	IDs  map[string]string `json:"ids"`
+}
+
+// Returns the file storing the listings of the calling shell session.
+func sessionFile() string {
+	dir := path.Join(os.TempDir(), fmt.Sprintf("rem-%d", os.Getuid()))
+	return path.Join(dir, fmt.Sprintf("session-%d.json", os.Getppid()))
+}
+
+// Reads the listings of the current session by rem file path.
+func readSession() map[string]*listing {
+	listings := map[string]*listing{}
+	data, err := ioutil.ReadFile(sessionFile())
+	if err != nil {
+		return listings
+	}
+	if err := json.Unmarshal(data, &listings); err != nil {
+		return map[string]*listing{}
+	}
+	return listings
+}
+
+func (r *Rem) recordListing(indexes []int) error {
+	// Remembers which lines were shown at which index in this session.
+	listings := readSession()
+	shown := &listing{Time: time.Now(), IDs: map[string]string{}}
+	for _, index := range indexes {
+		shown.IDs[strconv.Itoa(index)] = r.lines[index].id
+	}
+	listings[r.filepath] = shown
+
+	data, err := json.Marshal(listings)
+	if err != nil {
+		return err
+	}
+	file := sessionFile()
+	if err := os.MkdirAll(path.Dir(file), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0600)
+}
+
+func (r *Rem) checkListed(index int) error {
+	// Refuses index numbers pointing to another line than the one
+	// shown in the last listing of this session.
+	if r.force {
+		return nil
+	}
+	shown, ok := readSession()[r.filepath]
+	if !ok || time.Since(shown.Time) > listingMaxAge {
+		return nil
+	}
+	id, ok := shown.IDs[strconv.Itoa(index)]
+	if !ok || r.lines[index].id == id {
+		return nil
+	}
+	return fmt.Errorf("Line %d changed since it was listed, it was %s before. List again or use --force.", index, id)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCheckListed(t *testing.T) {
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	// nothing listed, nothing to check
+	if _, err := rem.resolve("1"); err != nil {
+		t.Errorf("Error for unlisted index, got %s", err)
+	}
+
+	rescueStdout := os.Stdout
+	os.Stdout, _ = os.Open(os.DevNull)
+	rem.printAllLines()
+	os.Stdout = rescueStdout
+
+	// another terminal removes the first line
+	other := &Rem{File: File{filename: testRemFile}}
+	if err := other.removeLine(0); err != nil {
+		t.Fatalf("Error when removing line, got %s", err)
+	}
+
+	rem = &Rem{File: File{filename: testRemFile}}
+	rem.read()
+	if _, err := rem.resolve("1"); err == nil {
+		t.Error("No error for index pointing to another line.")
+	}
+	if index, err := rem.resolve("foo"); err != nil || index != 0 {
+		t.Errorf("Tag not resolved, got %d, %v", index, err)
+	}
+
+	rem.force = true
+	if index, err := rem.resolve("1"); err != nil || index != 1 {
+		t.Errorf("Index not resolved with force, got %d, %v", index, err)
+	}
+}
+
+func TestCheckFiltered(t *testing.T) {
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	rescueStdout := os.Stdout
+	os.Stdout, _ = os.Open(os.DevNull)
+	rem.filterLines("echo")
+	os.Stdout = rescueStdout
+
+	other := &Rem{File: File{filename: testRemFile}}
+	if err := other.appendLine("pwd", ""); err != nil {
+		t.Fatalf("Error when appending line, got %s", err)
+	}
+	other.read()
+	if err := other.removeLine(1); err != nil {
+		t.Fatalf("Error when removing line, got %s", err)
+	}
+
+	rem.read()
+	if _, err := rem.resolve("2"); err == nil {
+		t.Error("No error for filtered index pointing to another line.")
+	}
+	if _, err := rem.resolve("0"); err != nil {
+		t.Errorf("Error for index not shown by filter, got %s", err)
+	}
+}
@@ -0,0 +1,107 @@
+// rem - A tool to remember things on the command line.
+// Copyright (C) 2015 Martin Borho (martin@borho.net)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Returns the directories or files with shared commands, the configured
+// ones, /etc/rem.d by default, followed by the ones in $REM_SHARED_PATH.
+func sharedPaths() []string {
+	paths := []string{}
+	for _, p := range filepath.SplitList(config.SharedPath + ":" + os.Getenv("REM_SHARED_PATH")) {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// Returns the existing rem files of the given paths, for directories
+// all *.rem files in them sorted by name.
+func sharedFiles(paths []string) []string {
+	files := []string{}
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		matches, _ := filepath.Glob(path.Join(p, "*.rem"))
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files
+}
+
+func (r *Rem) readShared() error {
+	// Reads the shared commands into a read-only layer, the lines keep
+	// the file they come from. Broken files get skipped with a warning,
+	// they shouldn't stop anyone from using their own rem files.
+	r.shared = nil
+	shared := &Rem{
+		printBeforeExec: r.printBeforeExec,
+		child:           r.child,
+		timing:          r.timing,
+		force:           r.force,
+		readonly:        true,
+	}
+	read := []string{}
+	for _, file := range sharedFiles(sharedPaths()) {
+		lines, err := readSharedFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rem: skipped shared file %s, %s\n", file, err)
+			continue
+		}
+		read = append(read, file)
+		for i, l := range lines {
+			if l.id == "" {
+				l.deriveID(i)
+			}
+			if len(l.tags) > 0 {
+				shared.hasTags = true
+			}
+			l.source = file
+		}
+		shared.lines = append(shared.lines, lines...)
+	}
+	if len(read) > 0 {
+		shared.filepath = strings.Join(read, ", ")
+		r.shared = shared
+	}
+	return nil
+}
+
+// Returns the lines of a shared rem file.
+func readSharedFile(file string) ([]*Line, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	lines, _, err := decodeLines(data)
+	return lines, err
+}
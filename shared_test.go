@@ -0,0 +1,131 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func getSharedRem(t *testing.T) (*Rem, string) {
+	// Creates a rem with shared commands from two files in a temp dir.
+	rem := getTestRem(t)
+	t.Cleanup(func() { removeRemFile(rem) })
+	dir := t.TempDir()
+	files := map[string]string{
+		"ops.rem": "#deploy#./deploy.sh\n#foo#ls -la /srv\n",
+		"db.rem":  "#psql#psql prod\n",
+		"notes":   "ignored\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("REM_SHARED_PATH", "/nonexistent:"+dir)
+	rem.read()
+	if err := rem.readShared(); err != nil {
+		t.Fatalf("Error reading shared commands, got %s", err)
+	}
+	return rem, dir
+}
+
+func TestReadShared(t *testing.T) {
+	rem, dir := getSharedRem(t)
+
+	if rem.shared == nil || len(rem.shared.lines) != 3 {
+		t.Fatalf("Shared commands not read, got %v", rem.shared)
+	}
+	// files sorted by name
+	if rem.shared.lines[0].cmd != "psql prod" || rem.shared.lines[0].source != path.Join(dir, "db.rem") {
+		t.Errorf("Wrong first shared line, got %+v", rem.shared.lines[0])
+	}
+	if rem.shared.label() != "shared" {
+		t.Errorf("Wrong label, got %s", rem.shared.label())
+	}
+}
+
+func TestReadSharedNone(t *testing.T) {
+	rem := getTestRem(t)
+	defer removeRemFile(rem)
+	rem.read()
+
+	if err := rem.readShared(); err != nil || rem.shared != nil {
+		t.Errorf("Shared layer without shared files, got %v %v", rem.shared, err)
+	}
+}
+
+func TestReadSharedBroken(t *testing.T) {
+	rem, dir := getSharedRem(t)
+	broken := path.Join(dir, "bad.rem")
+	if err := ioutil.WriteFile(broken, []byte("{\"format\":\"rem\",\"version\":3}\n{broken\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rescueStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	err := rem.readShared()
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+	os.Stderr = rescueStderr
+
+	if err != nil || rem.shared == nil || len(rem.shared.lines) != 3 || strings.Contains(rem.shared.filepath, broken) {
+		t.Errorf("Broken shared file not skipped, got %v %v", rem.shared, err)
+	}
+	if !strings.HasPrefix(string(out), "rem: skipped shared file "+broken) {
+		t.Errorf("No warning for broken shared file, got %s", out)
+	}
+}
+
+func TestLocateShared(t *testing.T) {
+	rem, _ := getSharedRem(t)
+
+	layer, index, err := rem.locate("deploy")
+	if err != nil || layer != rem.shared || index != 1 {
+		t.Errorf("Shared tag not found, got %d %v", index, err)
+	}
+	// local lines come first
+	layer, index, err = rem.locate("foo")
+	if err != nil || layer != rem || index != 1 {
+		t.Errorf("Local tag not preferred, got %d %v", index, err)
+	}
+	layer, index, err = rem.locate("shared:foo")
+	if err != nil || layer != rem.shared || index != 2 {
+		t.Errorf("Qualified shared tag not found, got %d %v", index, err)
+	}
+}
+
+func TestSharedReadonly(t *testing.T) {
+	rem, _ := getSharedRem(t)
+
+	if err := rem.shared.removeLine(0); err != errReadonly {
+		t.Errorf("Shared line removed, got %v", err)
+	}
+	if err := rem.shared.editIndex(0); err != errReadonly {
+		t.Errorf("Shared line edited, got %v", err)
+	}
+	if err := rem.shared.retag(0, []string{"db"}); err != errReadonly {
+		t.Errorf("Shared line tagged, got %v", err)
+	}
+}
+
+func TestFilterShared(t *testing.T) {
+	rem, _ := getSharedRem(t)
+
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	rem.filterLines("ls")
+
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+	os.Stdout = rescueStdout
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[3], "[shared] ") || !strings.Contains(lines[4], "ls -la /srv") {
+		t.Errorf("Wrong filter output, got %s", out)
+	}
+}
@@ -0,0 +1,159 @@
+// rem - A tool to remember things on the command line.
+// Copyright (C) 2015 Martin Borho (martin@borho.net)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var unsafeFuncChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// Returns a shell function name for a tag.
+func funcName(tag string) string {
+	name := unsafeFuncChars.ReplaceAllString(tag, "_")
+	if strings.HasPrefix(name, "-") {
+		name = "_" + name
+	}
+	return name
+}
+
+// Reserved words and builtins of bash, zsh and fish, functions with
+// these names are syntax errors or break the shell.
+var shellKeywords = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`
+		case coproc do done elif else end esac fi for foreach function if in
+		repeat select then time until while
+		alias and begin bg bind break builtin caller cd command compgen complete
+		continue declare dirs disown echo emulate enable eval exec exit export
+		false fc fg getopts hash help history jobs kill let local logout mapfile
+		noglob nocorrect not or popd printf pushd pwd read readarray readonly
+		return set setopt shift shopt source suspend switch test times trap true
+		type typeset ulimit umask unalias unset unsetopt wait whence`) {
+		shellKeywords[word] = true
+	}
+}
+
+// Quotes a string for fish, where backslashes escape in single quotes.
+func fishQuote(str string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(str) + "'"
+}
+
+// Returns the lines of a shell comment.
+func shellComment(str string) string {
+	return "# " + strings.ReplaceAll(str, "\n", "\n# ")
+}
+
+// Writes every tagged line as function for bash, zsh or fish, or as
+// fish abbreviation. Arguments of the function are appended to commands
+// which don't use them as positional parameters, if they can be. Lines
+// with placeholders and tags which are shell keywords are skipped, the
+// reasons get returned.
+func exportShell(lines []*Line, shell, source string, abbr bool) ([]byte, []string, error) {
+	if shell != "bash" && shell != "zsh" && shell != "fish" {
+		return nil, nil, fmt.Errorf("Unsupported shell %s, use bash, zsh or fish.", shell)
+	}
+	if abbr && shell != "fish" {
+		return nil, nil, errors.New("Abbreviations are only supported for fish.")
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Generated by rem export-shell --shell %s from %s\n", shell, source)
+	if shell == "fish" {
+		fmt.Fprintf(&buf, "# Load with: rem export-shell --shell fish | source\n")
+	} else {
+		fmt.Fprintf(&buf, "# Load with: eval \"$(rem export-shell --shell %s)\"\n", shell)
+	}
+
+	seen := map[string]bool{}
+	skipped := []string{}
+	for _, line := range lines {
+		if len(line.tags) == 0 {
+			continue
+		}
+		if len(line.placeholders()) > 0 {
+			skipped = append(skipped, line.tagList()+", commands with placeholders can't be exported")
+			continue
+		}
+		// without placeholders only escaped braces get replaced
+		cmd, _ := line.expand(map[string]string{}, false)
+		l := &Line{cmd: cmd, description: line.description}
+		multiLine := strings.Contains(l.cmd, "\n")
+		for _, tag := range line.tags {
+			name := funcName(tag)
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			if shellKeywords[name] {
+				skipped = append(skipped, tag+", it's a reserved word or builtin of the shell")
+				continue
+			}
+			// a function calling a command of the same name would call itself
+			body := l.cmd
+			if words := strings.Fields(body); len(words) > 0 && words[0] == name {
+				body = "command " + body
+			}
+			buf.WriteString("\n")
+			switch {
+			case shell == "fish" && abbr && !multiLine:
+				if l.description != "" {
+					fmt.Fprintln(&buf, shellComment(l.description))
+				}
+				fmt.Fprintf(&buf, "abbr --add %s %s\n", name, fishQuote(l.cmd))
+			case shell == "fish":
+				description := ""
+				if l.description != "" {
+					description = " --description " + fishQuote(l.description)
+				}
+				args := ""
+				if l.appendsArgs() {
+					args = " (string escape -- $argv)"
+				}
+				fmt.Fprintf(&buf, "function %s%s\n    eval %s%s\nend\n", name, description, fishQuote(body), args)
+			default:
+				if l.description != "" {
+					fmt.Fprintln(&buf, shellComment(l.description))
+				}
+				cmd := body
+				if l.appendsArgs() {
+					cmd += ` "$@"`
+				}
+				fmt.Fprintf(&buf, "%s() {\n    eval %s\n}\n", name, shellQuote(cmd))
+			}
+		}
+	}
+	return buf.Bytes(), skipped, nil
+}
+
+func (r *Rem) exportShell(shell string, abbr bool) error {
+	// Prints the tagged lines as shell functions.
+	data, skipped, err := exportShell(r.lines, shell, r.filepath, abbr)
+	if err != nil {
+		return err
+	}
+	for _, reason := range skipped {
+		fmt.Fprintf(os.Stderr, "rem: skipped %s.\n", reason)
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
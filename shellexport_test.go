@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getShellExportLines() []*Line {
+	return []*Line{
+		{cmd: "ls"},
+		{cmd: `printf '%s|' "it's" \$HOME`, tags: []string{"quote", "make:quote"}, description: "prints\narguments"},
+		{cmd: "for x in a b; do\n  echo \"$x\"\ndone", tags: []string{"loop"}},
+		{cmd: "date", tags: []string{"time"}},
+		{cmd: "basename /srv/app", tags: []string{"basename"}},
+		{cmd: `printf '%s|' "$1"`, tags: []string{"first"}},
+		{cmd: "echo done; ", tags: []string{"semi"}},
+		{cmd: "echo hi # greet", tags: []string{"comment"}},
+		{cmd: `echo '\{{x}}'`, tags: []string{"braces"}},
+		{cmd: "kubectl -n {{ns}} get pods", tags: []string{"pods"}},
+	}
+}
+
+func TestFuncName(t *testing.T) {
+	cases := map[string]string{"deploy": "deploy", "make:test": "make_test", "-x": "_-x", "a b/c": "a_b_c"}
+	for tag, expected := range cases {
+		if name := funcName(tag); name != expected {
+			t.Errorf("Wrong function name for %s, got %s", tag, name)
+		}
+	}
+}
+
+func TestExportShellBash(t *testing.T) {
+	data, skipped, err := exportShell(getShellExportLines(), "bash", "/tmp/.rem", false)
+	if err != nil {
+		t.Fatalf("Error exporting, got %s", err)
+	}
+	if len(skipped) != 2 || !strings.HasPrefix(skipped[0], "time, it's a reserved word") ||
+		!strings.HasPrefix(skipped[1], "pods, commands with placeholders") {
+		t.Errorf("Line with placeholders not skipped, got %v", skipped)
+	}
+	script := string(data)
+	if !strings.HasPrefix(script, "# Generated by rem export-shell --shell bash from /tmp/.rem\n") {
+		t.Errorf("Wrong header, got %s", script)
+	}
+	if !strings.Contains(script, "basename() {\n    eval 'command basename /srv/app \"$@\"'\n}") {
+		t.Errorf("Command not called with command, got %s", script)
+	}
+	if strings.Contains(script, "ls") || strings.Contains(script, "pods") || !strings.Contains(script, "# prints\n# arguments\nquote() {") {
+		t.Errorf("Wrong functions, got %s", script)
+	}
+
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not installed")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "bash", "-c", script+"\nquote 'a b'; make_quote; loop; basename; first a b; semi x; comment x; braces").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Error running exported functions, got %s %s", err, out)
+	}
+	if string(out) != "it's|$HOME|a b|it's|$HOME|a\nb\napp\na|done\nhi\n{{x}}\n" {
+		t.Errorf("Wrong output of exported functions, got %q", out)
+	}
+}
+
+func TestExportShellFish(t *testing.T) {
+	data, _, err := exportShell(getShellExportLines(), "fish", "/tmp/.rem", false)
+	if err != nil {
+		t.Fatalf("Error exporting, got %s", err)
+	}
+	expected := "function quote --description 'prints\narguments'\n    eval 'printf \\'%s|\\' \"it\\'s\" \\\\$HOME' (string escape -- $argv)\nend\n"
+	if !strings.Contains(string(data), expected) || !strings.Contains(string(data), "| source\n") {
+		t.Errorf("Wrong fish function, got %s", data)
+	}
+
+	if !strings.Contains(string(data), "function semi\n    eval 'echo done; '\nend\n") {
+		t.Errorf("Arguments appended after ;, got %s", data)
+	}
+
+	data, _, err = exportShell(getShellExportLines(), "fish", "/tmp/.rem", true)
+	if err != nil || !strings.Contains(string(data), "abbr --add quote 'printf") || !strings.Contains(string(data), "function loop\n") {
+		t.Errorf("Wrong fish abbreviations, got %s %v", data, err)
+	}
+}
+
+func TestExportShellErrors(t *testing.T) {
+	if _, _, err := exportShell(getShellExportLines(), "tcsh", "", false); err == nil {
+		t.Error("No error for unsupported shell.")
+	}
+	if _, _, err := exportShell(getShellExportLines(), "zsh", "", true); err == nil {
+		t.Error("No error for abbreviations in zsh.")
+	}
+}
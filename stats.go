@@ -0,0 +1,159 @@
+// rem - A tool to remember things on the command line.
+// Copyright (C) 2015 Martin Borho (martin@borho.net)
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Orders the listing can be sorted in.
+var sortOrders = []string{"frecency", "recent", "count", "alpha"}
+
+// Runs of a command taken from the log of run commands.
+type usage struct {
+	count    int
+	last     time.Time
+	duration float64 // seconds, all runs
+	score    float64 // frecency
+}
+
+// Returns how much a run counts for frecency, recent runs count more.
+func recencyWeight(age time.Duration) float64 {
+	switch {
+	case age < time.Hour:
+		return 4
+	case age < 24*time.Hour:
+		return 2
+	case age < 7*24*time.Hour:
+		return 1
+	case age < 30*24*time.Hour:
+		return 0.5
+	}
+	return 0.25
+}
+
+// Sums up the log of run commands by id.
+func readUsage(now time.Time) (map[string]*usage, error) {
+	stats := map[string]*usage{}
+	records, err := readExecLog(func(*execRecord) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		u, ok := stats[rec.ID]
+		if !ok {
+			u = &usage{}
+			stats[rec.ID] = u
+		}
+		u.count++
+		u.duration += rec.Duration
+		u.score += recencyWeight(now.Sub(rec.Start))
+		if rec.Start.After(u.last) {
+			u.last = rec.Start
+		}
+	}
+	return stats, nil
+}
+
+func (r *Rem) setSort(order string) error {
+	// Sets the order of listings, the log of run commands gets read
+	// for all but alpha.
+	for _, o := range sortOrders {
+		if o != order {
+			continue
+		}
+		r.sortBy = order
+		if order == "alpha" || r.usage != nil {
+			return nil
+		}
+		usage, err := readUsage(time.Now())
+		r.usage = usage
+		return err
+	}
+	return fmt.Errorf("Unknown sort order %s, use %s.", order, strings.Join(sortOrders, ", "))
+}
+
+func (r *Rem) usageOf(line *Line) *usage {
+	// Returns the runs of the line, empty if it never ran.
+	if u, ok := r.usage[line.id]; ok {
+		return u
+	}
+	return &usage{}
+}
+
+func (r *Rem) sortIndexes(lines []*Line, indexes []int) {
+	// Sorts the indexes of lines in the set order, lines which compare
+	// equal keep their order in the file.
+	name := func(l *Line) string {
+		if len(l.tags) > 0 {
+			return strings.ToLower(l.tagList())
+		}
+		return strings.ToLower(l.cmd)
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		a, b := lines[indexes[i]], lines[indexes[j]]
+		switch r.sortBy {
+		case "frecency":
+			return r.usageOf(a).score > r.usageOf(b).score
+		case "recent":
+			return r.usageOf(a).last.After(r.usageOf(b).last)
+		case "count":
+			return r.usageOf(a).count > r.usageOf(b).count
+		case "alpha":
+			return name(a) < name(b)
+		}
+		return false
+	})
+}
+
+func (r *Rem) printStats() error {
+	// Prints run count, last run and average duration of all lines,
+	// most often run first unless sorted otherwise.
+	if r.sortBy == "" {
+		if err := r.setSort("count"); err != nil {
+			return err
+		}
+	}
+	if r.usage == nil {
+		usage, err := readUsage(time.Now())
+		if err != nil {
+			return err
+		}
+		r.usage = usage
+	}
+	r.printMatching(func(line *Line) bool {
+		return true
+	}, func(w io.Writer, layer *Rem, x int, line *Line) {
+		u := r.usageOf(line)
+		last, avg := "-", "-"
+		if u.count > 0 {
+			last = u.last.Local().Format("2006-01-02 15:04")
+			avg = time.Duration(u.duration / float64(u.count) * float64(time.Second)).Round(time.Millisecond).String()
+		}
+		tag := line.tagList()
+		if tag == "" {
+			tag = " - "
+		}
+		fmt.Fprintf(w, " %d\t%s\t%s\truns %d\t%s\t%s\t%s\n", x, paint(colorID, line.id), paint(colorTag, tag),
+			u.count, last, avg, line.summary())
+	})
+	return nil
+}
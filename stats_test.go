@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeExecLog(t *testing.T, records ...*execRecord) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	for _, rec := range records {
+		if err := appendExecLog(rec); err != nil {
+			t.Fatalf("Cannot write exec log, %s", err)
+		}
+	}
+}
+
+func TestReadUsage(t *testing.T) {
+	now := time.Now()
+	writeExecLog(t,
+		&execRecord{ID: "abc234", Start: now.Add(-time.Minute), Duration: 1},
+		&execRecord{ID: "abc234", Start: now.Add(-48 * time.Hour), Duration: 3},
+		&execRecord{ID: "def567", Start: now.Add(-60 * 24 * time.Hour), Duration: 2},
+	)
+	stats, err := readUsage(now)
+	if err != nil || len(stats) != 2 {
+		t.Fatalf("Wrong usage, got %v %v", stats, err)
+	}
+	u := stats["abc234"]
+	if u.count != 2 || u.duration != 4 || u.score != 5 || !u.last.Equal(now.Add(-time.Minute)) {
+		t.Errorf("Wrong usage for abc234, got %+v", u)
+	}
+	if u := stats["def567"]; u.count != 1 || u.score != 0.25 {
+		t.Errorf("Wrong usage for def567, got %+v", u)
+	}
+}
+
+func TestSortIndexes(t *testing.T) {
+	now := time.Now()
+	// b runs often but not lately, c ran once right now
+	records := []*execRecord{{ID: "cccccc", Start: now}}
+	for i := 0; i < 5; i++ {
+		records = append(records, &execRecord{ID: "bbbbbb", Start: now.Add(-72 * time.Hour)})
+	}
+	writeExecLog(t, records...)
+	lines := []*Line{
+		{id: "aaaaaa", cmd: "zip", tags: []string{"Zip"}},
+		{id: "bbbbbb", cmd: "ls"},
+		{id: "cccccc", cmd: "make", tags: []string{"build"}},
+	}
+	cases := map[string]string{
+		"frecency": "[1 2 0]",
+		"recent":   "[2 1 0]",
+		"count":    "[1 2 0]",
+		"alpha":    "[2 1 0]",
+	}
+	for order, expected := range cases {
+		rem := &Rem{}
+		if err := rem.setSort(order); err != nil {
+			t.Fatalf("Error for %s, got %s", order, err)
+		}
+		indexes := []int{0, 1, 2}
+		rem.sortIndexes(lines, indexes)
+		if fmt.Sprint(indexes) != expected {
+			t.Errorf("Wrong order for %s, got %v", order, indexes)
+		}
+	}
+
+	rem := &Rem{}
+	if err := rem.setSort("size"); err == nil || rem.sortBy != "" {
+		t.Error("No error for unknown sort order.")
+	}
+}
+
+func TestPrintStats(t *testing.T) {
+	rem := getRem(t, "#a#echo a\n#b#echo b\n#c#echo c\n")
+	defer removeRemFile(rem)
+	rem.read()
+	writeExecLog(t,
+		&execRecord{ID: rem.lines[1].id, Start: time.Now(), Duration: 1},
+		&execRecord{ID: rem.lines[1].id, Start: time.Now(), Duration: 2},
+		&execRecord{ID: rem.lines[2].id, Start: time.Now(), Duration: 0.5},
+	)
+
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	err := rem.printStats()
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+	os.Stdout = rescueStdout
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if err != nil || len(lines) != 3 {
+		t.Fatalf("Wrong stats, got %s %v", out, err)
+	}
+	if fields := strings.Fields(lines[0]); fields[0] != "1" || fields[4] != "2" || fields[7] != "1.5s" {
+		t.Errorf("Most run command not first, got %s", lines[0])
+	}
+	if fields := strings.Fields(lines[2]); fields[0] != "0" || fields[4] != "0" || fields[5] != "-" || fields[6] != "-" {
+		t.Errorf("Never run command not last, got %s", lines[2])
+	}
+}
@@ -17,12 +17,23 @@
 package main
 
 import (
+	"bufio"
 	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"os/exec"
+	"os/user"
+	"path"
+	"regexp"
 	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
 )
 
+var safeShellWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
 func toInt(str string) (int, error) {
 	integer, err := strconv.Atoi(str)
 	if err != nil {
@@ -31,7 +42,105 @@ func toInt(str string) (int, error) {
 	return integer, err
 }
 
+// Exit status of a command run as child process.
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("Command exited with status %d.", e.code)
+}
+
 func exit(msg error) {
+	// exit like the command did
+	var exitErr *exitError
+	if errors.As(msg, &exitErr) {
+		os.Exit(exitErr.code)
+	}
 	fmt.Println(msg)
 	os.Exit(1)
 }
+
+// Quotes a string for POSIX shells if it contains special characters.
+func shellQuote(str string) string {
+	if safeShellWord.MatchString(str) {
+		return str
+	}
+	return "'" + strings.ReplaceAll(str, "'", `'\''`) + "'"
+}
+
+// Joins arguments to a command line the shell splits into the same arguments.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Returns the name of the current user, from git config or $USER.
+func currentAuthor() string {
+	if out, err := exec.Command("git", "config", "user.name").Output(); err == nil {
+		if name := strings.TrimSpace(string(out)); name != "" {
+			return name
+		}
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	if usr, err := user.Current(); err == nil {
+		return usr.Username
+	}
+	return ""
+}
+
+// Parses flags and positional arguments in any order, returns the
+// positional ones.
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// Checks if the file is a terminal, other character devices like
+// /dev/null aren't.
+func isTerminal(f *os.File) bool {
+	_, err := unix.IoctlGetTermios(int(f.Fd()), ioctlReadTermios)
+	return err == nil
+}
+
+// Replaces a leading ~/ with the home dir of the current user.
+func expandHome(p string) string {
+	if !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	if usr, err := user.Current(); err == nil {
+		return path.Join(usr.HomeDir, p[2:])
+	}
+	return p
+}
+
+// Returns the login name of the current user.
+func currentUser() string {
+	if usr, err := user.Current(); err == nil {
+		return usr.Username
+	}
+	return os.Getenv("USER")
+}
+
+// Asks a yes/no question on stdin, anything but yes is a no.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
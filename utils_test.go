@@ -2,8 +2,10 @@ package main
 
 import (
 	_ "errors"
-	_ "os"
-	_ "os/exec"
+	"flag"
+	"os"
+	"os/exec"
+	"strings"
 	"testing"
 )
 
@@ -30,6 +32,38 @@ func TestToInt(t *testing.T) {
 
 }
 
+func TestShellQuote(t *testing.T) {
+	cases := map[string]string{
+		"ls":           "ls",
+		"--color=auto": "--color=auto",
+		"foo bar":      "'foo bar'",
+		"":             "''",
+		"it's":         `'it'\''s'`,
+		"$HOME":        "'$HOME'",
+		"a|b":          "'a|b'",
+	}
+	for arg, expected := range cases {
+		if quoted := shellQuote(arg); quoted != expected {
+			t.Errorf("Wrong quoting for %s, want %s, got %s", arg, expected, quoted)
+		}
+	}
+}
+
+func TestShellJoin(t *testing.T) {
+	args := []string{"grep", "foo bar", "it's", "", "$(pwd)", "a\nb", `back\slash`, "*.go"}
+	joined := shellJoin(args)
+
+	// the shell has to split the joined line into the same arguments
+	out, err := exec.Command("/bin/sh", "-c", "set -- "+joined+`; printf '%s\0' "$@"`).Output()
+	if err != nil {
+		t.Fatalf("Error running shell, got %s", err)
+	}
+	got := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	if strings.Join(got, "|") != strings.Join(args, "|") {
+		t.Errorf("Arguments changed, want %q, got %q", args, got)
+	}
+}
+
 /*func TestExit(t *testing.T) {
 	exitError := errors.New("Test-Error")
 	if os.Getenv("BE_CRASHER") == "1" {
@@ -44,3 +78,32 @@ func TestToInt(t *testing.T) {
 	}
 	t.Fatalf("Process ran with err %v, want exit status 1", err)
 }*/
+
+func TestParseArgs(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	format := flags.String("format", "", "")
+	renumber := flags.Bool("renumber", false, "")
+	args, err := parseArgs(flags, []string{"--renumber", "a.json", "--format", "json", "b.json"})
+	if err != nil || len(args) != 2 || args[0] != "a.json" || args[1] != "b.json" {
+		t.Errorf("Wrong arguments, got %v %v", args, err)
+	}
+	if *format != "json" || !*renumber {
+		t.Errorf("Flags not parsed, got %s %v", *format, *renumber)
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer null.Close()
+	r, w, _ := os.Pipe()
+	defer r.Close()
+	defer w.Close()
+	for _, f := range []*os.File{null, r, w} {
+		if isTerminal(f) {
+			t.Errorf("%s taken as terminal.", f.Name())
+		}
+	}
+}